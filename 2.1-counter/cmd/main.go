@@ -0,0 +1,84 @@
+package main
+
+import (
+	"counter"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+func main() {
+	delay := flag.Duration("delay", 10*time.Minute, "delay between counts")
+	format := flag.String("format", "text", "output format: text, json or csv")
+	cronExpr := flag.String("cron", "", "tick on a cron schedule instead of a fixed delay")
+	timeZone := flag.String("tz", "Local", "time zone for the cron schedule")
+	ids := flag.Int("ids", 0, "print this many unique IDs instead of counting")
+	node := flag.Int("node", 0, "node number embedded in generated IDs")
+	encoding := flag.String("encoding", "decimal", "ID encoding: decimal, base32 or base62")
+	flag.Parse()
+
+	if *ids > 0 {
+		if err := printIDs(*ids, *node, *encoding); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	c := counter.NewCounter()
+	c.Delay = *delay
+	if *cronExpr != "" {
+		location, err := time.LoadLocation(*timeZone)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		c.Schedule, err = counter.ParseCron(*cronExpr, location)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+	switch *format {
+	case "text":
+		c.Sink = counter.TextSink(os.Stdout)
+	case "json":
+		c.Sink = counter.JSONSink(os.Stdout)
+	case "csv":
+		c.Sink = counter.CSVSink(os.Stdout)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown format %q\n", *format)
+		os.Exit(2)
+	}
+
+	handleSignals(&c)
+	if err := c.Run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func printIDs(count, node int, encoding string) error {
+	g, err := counter.NewIDGenerator(node)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < count; i++ {
+		id, err := g.Next()
+		if err != nil {
+			return err
+		}
+		switch encoding {
+		case "decimal":
+			fmt.Println(int64(id))
+		case "base32":
+			fmt.Println(id.Base32())
+		case "base62":
+			fmt.Println(id.Base62())
+		default:
+			return fmt.Errorf("unknown encoding %q", encoding)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"counter"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// handleSignals toggles pausing on SIGUSR1 and steps on SIGUSR2.
+func handleSignals(c *counter.Counter) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1, syscall.SIGUSR2)
+	go func() {
+		for sig := range signals {
+			switch sig {
+			case syscall.SIGUSR1:
+				if c.Paused() {
+					c.Resume()
+				} else {
+					c.Pause()
+				}
+			case syscall.SIGUSR2:
+				c.Step()
+			}
+		}
+	}()
+}
@@ -0,0 +1,6 @@
+package main
+
+import "counter"
+
+// handleSignals does nothing, as Windows has no SIGUSR1 or SIGUSR2.
+func handleSignals(c *counter.Counter) {}
@@ -1,9 +1,9 @@
 package counter
 
 import (
-	"fmt"
 	"io"
 	"os"
+	"sync"
 	"time"
 )
 
@@ -12,6 +12,17 @@ type Counter struct {
 	Writer  io.Writer
 	Stop    chan bool
 	Delay   time.Duration
+	Sink    Sink
+	Retry   RetryPolicy
+	// Schedule, when set, replaces the fixed Delay between ticks.
+	Schedule Schedule
+	Clock    Clock
+
+	mu       sync.Mutex
+	paused   bool
+	steps    int
+	schedule bool
+	wake     chan struct{}
 }
 
 func NewCounter() Counter {
@@ -28,14 +39,162 @@ func (counter *Counter) Next() int {
 	return current
 }
 
-func (counter *Counter) Run() {
+func (counter *Counter) Pause() {
+	counter.mu.Lock()
+	defer counter.mu.Unlock()
+	counter.paused = true
+	counter.notify()
+}
+
+func (counter *Counter) Resume() {
+	counter.mu.Lock()
+	defer counter.mu.Unlock()
+	counter.paused = false
+	counter.schedule = true
+	counter.notify()
+}
+
+func (counter *Counter) Paused() bool {
+	counter.mu.Lock()
+	defer counter.mu.Unlock()
+	return counter.paused
+}
+
+func (counter *Counter) SetDelay(delay time.Duration) {
+	counter.mu.Lock()
+	defer counter.mu.Unlock()
+	counter.Delay = delay
+	counter.schedule = true
+	counter.notify()
+}
+
+func (counter *Counter) Step() {
+	counter.mu.Lock()
+	defer counter.mu.Unlock()
+	counter.steps++
+	counter.notify()
+}
+
+// notify wakes up Run; the caller must hold counter.mu.
+func (counter *Counter) notify() {
+	select {
+	case counter.wakeup() <- struct{}{}:
+	default:
+	}
+}
+
+// wakeup lazily creates the wake channel, so that a Counter built as a struct
+// literal is usable; the caller must hold counter.mu.
+func (counter *Counter) wakeup() chan struct{} {
+	if counter.wake == nil {
+		counter.wake = make(chan struct{}, 1)
+	}
+	return counter.wake
+}
+
+type Clock interface {
+	Now() time.Time
+	After(time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (counter *Counter) clock() Clock {
+	if counter.Clock == nil {
+		return realClock{}
+	}
+	return counter.Clock
+}
+
+// nextTick returns when the tick following the one at last is due, or the zero
+// time if the schedule never fires again.
+func (counter *Counter) nextTick(last time.Time) time.Time {
+	if counter.Schedule != nil {
+		return counter.Schedule.Next(last)
+	}
+	counter.mu.Lock()
+	defer counter.mu.Unlock()
+	return last.Add(counter.Delay)
+}
+
+func (counter *Counter) emit() error {
+	sink := counter.Sink
+	if sink == nil {
+		sink = TextSink(counter.Writer)
+	}
+	record := Record{
+		Time: counter.clock().Now(),
+		Seq:  counter.counter,
+	}
+	if err := counter.Retry.emit(sink, record, counter.clock(), counter.Stop); err != nil {
+		return err
+	}
+	counter.Next()
+	return nil
+}
+
+func (counter *Counter) Run() error {
+	counter.mu.Lock()
+	wake := counter.wakeup()
+	paused := counter.paused
+	counter.mu.Unlock()
+
+	clock := counter.clock()
+	lastTick := clock.Now()
+	nextTick := counter.nextTick(lastTick)
+	// a timer is only started when the next tick changes, as abandoned ones
+	// live on until they fire
+	var tick <-chan time.Time
+	rearm := true
 	for {
+		if rearm {
+			tick = nil
+			if !paused && !nextTick.IsZero() {
+				tick = clock.After(nextTick.Sub(clock.Now()))
+			}
+			rearm = false
+		}
 		select {
 		case <-counter.Stop:
-			return
-		default:
-			time.Sleep(counter.Delay)
-			fmt.Fprintln(counter.Writer, counter.Next())
+			return nil
+		case <-wake:
+			counter.mu.Lock()
+			steps, schedule, nowPaused := counter.steps, counter.schedule, counter.paused
+			counter.steps, counter.schedule = 0, false
+			counter.mu.Unlock()
+			for ; steps > 0; steps-- {
+				if err := counter.emit(); err == errStopped {
+					return nil
+				} else if err != nil {
+					return err
+				}
+			}
+			if paused && !nowPaused {
+				lastTick = clock.Now()
+				schedule = true
+			}
+			rearm = schedule || paused != nowPaused
+			paused = nowPaused
+			if schedule {
+				nextTick = counter.nextTick(lastTick)
+			}
+		case <-tick:
+			lastTick = clock.Now()
+			if err := counter.emit(); err == errStopped {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			nextTick = counter.nextTick(lastTick)
+			rearm = true
 		}
 	}
 }
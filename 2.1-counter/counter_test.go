@@ -48,3 +48,144 @@ func TestCounterRun(t *testing.T) {
 		t.Errorf("expected Run() to output %#v etc; got %#v", want, got)
 	}
 }
+
+type lineWriter chan string
+
+func (w lineWriter) Write(p []byte) (int, error) {
+	w <- string(p)
+	return len(p), nil
+}
+
+func expectLine(t *testing.T, lines lineWriter, want string) {
+	t.Helper()
+	select {
+	case got := <-lines:
+		if got != want {
+			t.Errorf("want: %#v, got: %#v", want, got)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for %#v", want)
+	}
+}
+
+func expectNoLine(t *testing.T, lines lineWriter, wait time.Duration) {
+	t.Helper()
+	select {
+	case got := <-lines:
+		t.Errorf("want no output, got: %#v", got)
+	case <-time.After(wait):
+	}
+}
+
+func TestCounterStopInterruptsDelay(t *testing.T) {
+	t.Parallel()
+	counter := counter.NewCounter()
+	counter.Delay = time.Hour
+	done := make(chan bool)
+	go func() {
+		counter.Run()
+		done <- true
+	}()
+	counter.Stop <- true
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run() did not return on Stop")
+	}
+}
+
+// expectWaiting waits for Run to wait on the clock for the next tick.
+func expectWaiting(t *testing.T, clock *fakeClock) {
+	t.Helper()
+	select {
+	case <-clock.waiting:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for Run() to wait for a tick")
+	}
+}
+
+func stop(t *testing.T, c *counter.Counter) {
+	t.Helper()
+	select {
+	case c.Stop <- true:
+	case <-time.After(time.Second):
+		t.Error("Run() did not take Stop")
+	}
+}
+
+func TestCounterStep(t *testing.T) {
+	t.Parallel()
+	clock := newFakeClock(time.Date(2021, 11, 5, 12, 0, 0, 0, time.UTC))
+	lines := make(lineWriter, 10)
+	counter := counter.NewCounter()
+	counter.Writer = lines
+	counter.Delay = time.Hour
+	counter.Clock = clock
+	go counter.Run()
+	defer stop(t, &counter)
+	expectWaiting(t, clock)
+	counter.Step()
+	expectLine(t, lines, "0\n")
+	counter.Step()
+	expectLine(t, lines, "1\n")
+	// steps leave the next tick alone, so they start no timers
+	select {
+	case <-clock.waiting:
+		t.Error("want no timer started by a step")
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func TestCounterPauseResume(t *testing.T) {
+	t.Parallel()
+	start := time.Date(2021, 11, 5, 12, 0, 0, 0, time.UTC)
+	clock := newFakeClock(start)
+	lines := make(lineWriter, 10)
+	counter := counter.NewCounter()
+	counter.Writer = lines
+	counter.Delay = time.Minute
+	counter.Clock = clock
+	go counter.Run()
+	defer stop(t, &counter)
+	expectWaiting(t, clock)
+	clock.Set(start.Add(time.Minute))
+	expectLine(t, lines, "0\n")
+	expectWaiting(t, clock)
+	counter.Pause()
+	if !counter.Paused() {
+		t.Error("want Paused() after Pause()")
+	}
+	// the step is taken after the pause, so ticks are off once it is out
+	counter.Step()
+	expectLine(t, lines, "1\n")
+	clock.Set(start.Add(time.Hour))
+	expectNoLine(t, lines, 10*time.Millisecond)
+	counter.Resume()
+	if counter.Paused() {
+		t.Error("want !Paused() after Resume()")
+	}
+	expectWaiting(t, clock)
+	clock.Set(start.Add(time.Hour + time.Minute))
+	expectLine(t, lines, "2\n")
+}
+
+func TestCounterSetDelay(t *testing.T) {
+	t.Parallel()
+	start := time.Date(2021, 11, 5, 12, 0, 0, 0, time.UTC)
+	clock := newFakeClock(start)
+	lines := make(lineWriter, 10)
+	counter := counter.NewCounter()
+	counter.Writer = lines
+	counter.Delay = time.Hour
+	counter.Clock = clock
+	go counter.Run()
+	defer stop(t, &counter)
+	expectWaiting(t, clock)
+	counter.SetDelay(time.Minute)
+	expectWaiting(t, clock)
+	clock.Set(start.Add(time.Minute))
+	expectLine(t, lines, "0\n")
+	expectWaiting(t, clock)
+	clock.Set(start.Add(2 * time.Minute))
+	expectLine(t, lines, "1\n")
+}
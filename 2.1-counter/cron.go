@@ -0,0 +1,183 @@
+package counter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Schedule interface {
+	Next(time.Time) time.Time
+}
+
+type Cron struct {
+	minute, hour, dom, month, dow uint64
+	domStar, dowStar              bool
+	location                      *time.Location
+}
+
+type cronField struct {
+	name     string
+	min, max int
+	names    []string
+}
+
+var cronFields = []cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: []string{
+		"", "jan", "feb", "mar", "apr", "may", "jun",
+		"jul", "aug", "sep", "oct", "nov", "dec",
+	}},
+	// 7 is accepted as an alias for Sunday and folded onto 0
+	{name: "day of week", min: 0, max: 7, names: []string{
+		"sun", "mon", "tue", "wed", "thu", "fri", "sat",
+	}},
+}
+
+// how far ahead Next looks before giving up on expressions like "0 0 30 2 *"
+const cronSearchYears = 5
+
+func ParseCron(expr string, location *time.Location) (*Cron, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("cron expression %q: want %d fields, got %d", expr, len(cronFields), len(fields))
+	}
+	if location == nil {
+		location = time.Local
+	}
+	bits := make([]uint64, len(fields))
+	for i, field := range fields {
+		var err error
+		bits[i], err = cronFields[i].parse(field)
+		if err != nil {
+			return nil, fmt.Errorf("cron expression %q: %w", expr, err)
+		}
+	}
+	if bits[4]&(1<<7) != 0 {
+		bits[4] |= 1
+	}
+	return &Cron{
+		minute:   bits[0],
+		hour:     bits[1],
+		dom:      bits[2],
+		month:    bits[3],
+		dow:      bits[4],
+		domStar:  strings.HasPrefix(fields[2], "*"),
+		dowStar:  strings.HasPrefix(fields[4], "*"),
+		location: location,
+	}, nil
+}
+
+func (field cronField) parse(spec string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(spec, ",") {
+		rangeSpec, stepSpec, hasStep := cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepSpec)
+			if err != nil || step < 1 {
+				return 0, fmt.Errorf("%s: invalid step %q", field.name, stepSpec)
+			}
+		}
+		low, high := field.min, field.max
+		if rangeSpec != "*" {
+			lowSpec, highSpec, isRange := cut(rangeSpec, "-")
+			var err error
+			if low, err = field.value(lowSpec); err != nil {
+				return 0, err
+			}
+			high = low
+			if isRange {
+				if high, err = field.value(highSpec); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				high = field.max
+			}
+			if low > high {
+				return 0, fmt.Errorf("%s: invalid range %q", field.name, rangeSpec)
+			}
+		}
+		for value := low; value <= high; value += step {
+			bits |= 1 << value
+		}
+	}
+	return bits, nil
+}
+
+func (field cronField) value(spec string) (int, error) {
+	for value, name := range field.names {
+		if name != "" && strings.EqualFold(spec, name) {
+			return value, nil
+		}
+	}
+	value, err := strconv.Atoi(spec)
+	if err != nil || value < field.min || value > field.max {
+		return 0, fmt.Errorf("%s: invalid value %q", field.name, spec)
+	}
+	return value, nil
+}
+
+func cut(s, sep string) (before, after string, found bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+// Next returns the first matching minute strictly after t, or the zero time if
+// there is none within a few years. Wall-clock minutes skipped by a DST jump
+// never fire; minutes repeated when the clock falls back fire twice.
+func (cron *Cron) Next(t time.Time) time.Time {
+	t = t.In(cron.location).Truncate(time.Minute).Add(time.Minute)
+	limit := t.Year() + cronSearchYears
+wrap:
+	for t.Year() <= limit {
+		for !has(cron.month, int(t.Month())) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, cron.location)
+			if t.Year() > limit {
+				return time.Time{}
+			}
+		}
+		for !cron.dayMatches(t) {
+			month := t.Month()
+			t = time.Date(t.Year(), month, t.Day()+1, 0, 0, 0, 0, cron.location)
+			if t.Month() != month {
+				continue wrap
+			}
+		}
+		for !has(cron.hour, t.Hour()) {
+			day := t.Day()
+			t = t.Add(-time.Duration(t.Minute()) * time.Minute).Add(time.Hour)
+			if t.Day() != day {
+				continue wrap
+			}
+		}
+		for !has(cron.minute, t.Minute()) {
+			hour := t.Hour()
+			t = t.Add(time.Minute)
+			if t.Hour() != hour {
+				continue wrap
+			}
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (cron *Cron) dayMatches(t time.Time) bool {
+	domMatch := has(cron.dom, t.Day())
+	dowMatch := has(cron.dow, int(t.Weekday()))
+	if cron.domStar || cron.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+func has(bits uint64, value int) bool {
+	return bits&(1<<value) != 0
+}
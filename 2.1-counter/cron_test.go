@@ -0,0 +1,222 @@
+package counter_test
+
+import (
+	"bytes"
+	"counter"
+	"sync"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return location
+}
+
+func TestParseCronErrors(t *testing.T) {
+	t.Parallel()
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"* * * foo *",
+	} {
+		if _, err := counter.ParseCron(expr, time.UTC); err == nil {
+			t.Errorf("want error for %q", expr)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	t.Parallel()
+	newYork := mustLoadLocation(t, "America/New_York")
+	tests := []struct {
+		expr string
+		from string
+		want []string
+	}{
+		{
+			expr: "*/15 9-17 * * 1-5",
+			from: "2021-11-05T17:40:00-04:00", // Friday
+			want: []string{
+				"2021-11-05T17:45:00-04:00",
+				"2021-11-08T09:00:00-05:00",
+				"2021-11-08T09:15:00-05:00",
+			},
+		},
+		{
+			expr: "0 12 1 jan,jul *",
+			from: "2021-07-01T12:00:00-04:00",
+			want: []string{
+				"2022-01-01T12:00:00-05:00",
+				"2022-07-01T12:00:00-04:00",
+			},
+		},
+		{
+			// day of month and day of week are or-ed when both are restricted
+			expr: "0 0 13 * fri",
+			from: "2021-08-01T00:00:00-04:00",
+			want: []string{
+				"2021-08-06T00:00:00-04:00",
+				"2021-08-13T00:00:00-04:00",
+				"2021-08-20T00:00:00-04:00",
+			},
+		},
+		{
+			expr: "0 0 * * 7",
+			from: "2021-08-01T00:00:00-04:00",
+			want: []string{"2021-08-08T00:00:00-04:00"},
+		},
+		{
+			// 02:30 does not exist on the day clocks spring forward
+			expr: "30 2 * * *",
+			from: "2021-03-13T03:00:00-05:00",
+			want: []string{
+				"2021-03-15T02:30:00-04:00",
+			},
+		},
+		{
+			// 01:30 happens twice on the day clocks fall back
+			expr: "30 1 * * *",
+			from: "2021-11-07T00:00:00-04:00",
+			want: []string{
+				"2021-11-07T01:30:00-04:00",
+				"2021-11-07T01:30:00-05:00",
+				"2021-11-08T01:30:00-05:00",
+			},
+		},
+		{
+			expr: "0 * * * *",
+			from: "2021-03-14T00:30:00-05:00",
+			want: []string{
+				"2021-03-14T01:00:00-05:00",
+				"2021-03-14T03:00:00-04:00",
+			},
+		},
+		{
+			expr: "0 0 30 2 *",
+			from: "2021-01-01T00:00:00-05:00",
+			want: []string{"0001-01-01T00:00:00Z"},
+		},
+	}
+	for _, test := range tests {
+		cron, err := counter.ParseCron(test.expr, newYork)
+		if err != nil {
+			t.Fatal(err)
+		}
+		from, err := time.Parse(time.RFC3339, test.from)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range test.want {
+			next := cron.Next(from)
+			got := next.Format(time.RFC3339)
+			if next.IsZero() {
+				got = next.UTC().Format(time.RFC3339)
+			}
+			if got != want {
+				t.Errorf("%q after %s: want %s, got %s", test.expr, from.Format(time.RFC3339), want, got)
+				break
+			}
+			from = next
+		}
+	}
+}
+
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+	waiting chan bool
+}
+
+type fakeWaiter struct {
+	deadline time.Time
+	c        chan time.Time
+}
+
+func newFakeClock(now time.Time) *fakeClock {
+	return &fakeClock{now: now, waiting: make(chan bool, 100)}
+}
+
+func (clock *fakeClock) Now() time.Time {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	return clock.now
+}
+
+func (clock *fakeClock) After(d time.Duration) <-chan time.Time {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	c := make(chan time.Time, 1)
+	clock.waiters = append(clock.waiters, fakeWaiter{clock.now.Add(d), c})
+	clock.waiting <- true
+	return c
+}
+
+func (clock *fakeClock) Set(now time.Time) {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	clock.now = now
+	pending := clock.waiters[:0]
+	for _, waiter := range clock.waiters {
+		if waiter.deadline.After(now) {
+			pending = append(pending, waiter)
+		} else {
+			waiter.c <- now
+		}
+	}
+	clock.waiters = pending
+}
+
+func TestCounterRunOnCron(t *testing.T) {
+	t.Parallel()
+	newYork := mustLoadLocation(t, "America/New_York")
+	cron, err := counter.ParseCron("*/15 9-17 * * 1-5", newYork)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := newFakeClock(time.Date(2021, 11, 5, 17, 40, 0, 0, newYork))
+	buf := &bytes.Buffer{}
+	c := counter.NewCounter()
+	c.Sink = counter.JSONSink(buf)
+	c.Schedule = cron
+	c.Clock = clock
+	go c.Run()
+	<-clock.waiting
+	for _, step := range []struct {
+		now   time.Time
+		fires bool
+	}{
+		{time.Date(2021, 11, 5, 17, 44, 0, 0, newYork), false},
+		{time.Date(2021, 11, 5, 17, 45, 0, 0, newYork), true},
+		{time.Date(2021, 11, 7, 12, 0, 0, 0, newYork), false},
+		{time.Date(2021, 11, 8, 9, 0, 0, 0, newYork), true},
+	} {
+		clock.Set(step.now)
+		if step.fires {
+			<-clock.waiting
+		}
+	}
+	c.Stop <- true
+	want := `{"time":"2021-11-05T17:45:00-04:00","seq":0}
+{"time":"2021-11-08T09:00:00-05:00","seq":1}
+`
+	got := buf.String()
+	if got != want {
+		t.Errorf("want: %#v, got: %#v", want, got)
+	}
+}
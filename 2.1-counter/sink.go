@@ -0,0 +1,128 @@
+package counter
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+type Record struct {
+	Time time.Time
+	Seq  int
+}
+
+type Sink interface {
+	Emit(Record) error
+}
+
+type SinkFunc func(Record) error
+
+func (f SinkFunc) Emit(record Record) error {
+	return f(record)
+}
+
+func TextSink(writer io.Writer) Sink {
+	return SinkFunc(func(record Record) error {
+		_, err := fmt.Fprintln(writer, record.Seq)
+		return err
+	})
+}
+
+type jsonRecord struct {
+	Time string `json:"time"`
+	Seq  int    `json:"seq"`
+}
+
+func JSONSink(writer io.Writer) Sink {
+	encoder := json.NewEncoder(writer)
+	return SinkFunc(func(record Record) error {
+		return encoder.Encode(jsonRecord{
+			Time: record.Time.Format(time.RFC3339Nano),
+			Seq:  record.Seq,
+		})
+	})
+}
+
+func CSVSink(writer io.Writer) Sink {
+	csvWriter := csv.NewWriter(writer)
+	header := []string{"time", "seq"}
+	return SinkFunc(func(record Record) error {
+		if header != nil {
+			if err := csvWriter.Write(header); err != nil {
+				return err
+			}
+			header = nil
+		}
+		err := csvWriter.Write([]string{
+			record.Time.Format(time.RFC3339Nano),
+			strconv.Itoa(record.Seq),
+		})
+		if err != nil {
+			return err
+		}
+		csvWriter.Flush()
+		return csvWriter.Error()
+	})
+}
+
+// MultiSink emits each record to all of sinks. When a record fails, emitting
+// it again, as a RetryPolicy does, only emits it to the sinks that failed.
+func MultiSink(sinks ...Sink) Sink {
+	return &multiSink{sinks: sinks, done: make([]bool, len(sinks))}
+}
+
+type multiSink struct {
+	sinks []Sink
+	// last is the record emitted last, and done tells which sinks took it
+	last Record
+	done []bool
+}
+
+func (multi *multiSink) Emit(record Record) error {
+	if record.Seq != multi.last.Seq || !record.Time.Equal(multi.last.Time) {
+		multi.last = record
+		for i := range multi.done {
+			multi.done[i] = false
+		}
+	}
+	var firstErr error
+	for i, sink := range multi.sinks {
+		if multi.done[i] {
+			continue
+		}
+		if err := sink.Emit(record); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		multi.done[i] = true
+	}
+	return firstErr
+}
+
+type RetryPolicy struct {
+	Retries int
+	Backoff time.Duration
+}
+
+// errStopped is returned by emit when the counter is stopped while waiting to
+// retry.
+var errStopped = errors.New("counter stopped")
+
+func (policy RetryPolicy) emit(sink Sink, record Record, clock Clock, stop <-chan bool) error {
+	err := sink.Emit(record)
+	for attempt := 0; err != nil && attempt < policy.Retries; attempt++ {
+		select {
+		case <-clock.After(policy.Backoff):
+		case <-stop:
+			return errStopped
+		}
+		err = sink.Emit(record)
+	}
+	return err
+}
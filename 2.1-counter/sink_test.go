@@ -0,0 +1,173 @@
+package counter_test
+
+import (
+	"bytes"
+	"counter"
+	"errors"
+	"testing"
+	"time"
+)
+
+var testTime = time.Date(2021, 11, 5, 14, 30, 0, 0, time.UTC)
+
+func emitAll(t *testing.T, sink counter.Sink, count int) {
+	t.Helper()
+	for seq := 0; seq < count; seq++ {
+		err := sink.Emit(counter.Record{Time: testTime, Seq: seq})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestTextSink(t *testing.T) {
+	t.Parallel()
+	buf := &bytes.Buffer{}
+	emitAll(t, counter.TextSink(buf), 2)
+	want := "0\n1\n"
+	got := buf.String()
+	if got != want {
+		t.Errorf("want: %#v, got: %#v", want, got)
+	}
+}
+
+func TestJSONSink(t *testing.T) {
+	t.Parallel()
+	buf := &bytes.Buffer{}
+	emitAll(t, counter.JSONSink(buf), 2)
+	want := `{"time":"2021-11-05T14:30:00Z","seq":0}
+{"time":"2021-11-05T14:30:00Z","seq":1}
+`
+	got := buf.String()
+	if got != want {
+		t.Errorf("want: %#v, got: %#v", want, got)
+	}
+}
+
+func TestCSVSink(t *testing.T) {
+	t.Parallel()
+	buf := &bytes.Buffer{}
+	emitAll(t, counter.CSVSink(buf), 2)
+	want := "time,seq\n2021-11-05T14:30:00Z,0\n2021-11-05T14:30:00Z,1\n"
+	got := buf.String()
+	if got != want {
+		t.Errorf("want: %#v, got: %#v", want, got)
+	}
+}
+
+func TestMultiSink(t *testing.T) {
+	t.Parallel()
+	text := &bytes.Buffer{}
+	csv := &bytes.Buffer{}
+	emitAll(t, counter.MultiSink(counter.TextSink(text), counter.CSVSink(csv)), 1)
+	want := "0\n"
+	got := text.String()
+	if got != want {
+		t.Errorf("want: %#v, got: %#v", want, got)
+	}
+	want = "time,seq\n2021-11-05T14:30:00Z,0\n"
+	got = csv.String()
+	if got != want {
+		t.Errorf("want: %#v, got: %#v", want, got)
+	}
+}
+
+func failingSink(failures int, emitted chan<- int) counter.Sink {
+	return counter.SinkFunc(func(record counter.Record) error {
+		if failures > 0 {
+			failures--
+			return errors.New("write failed")
+		}
+		emitted <- record.Seq
+		return nil
+	})
+}
+
+func TestRunStopsOnSinkError(t *testing.T) {
+	t.Parallel()
+	emitted := make(chan int, 1)
+	counter := counter.NewCounter()
+	counter.Delay = 0
+	counter.Sink = failingSink(1, emitted)
+	err := counter.Run()
+	if err == nil {
+		t.Error("want Run() to return the sink error")
+	}
+	if len(emitted) != 0 {
+		t.Errorf("want nothing emitted, got %v", <-emitted)
+	}
+}
+
+func TestRunRetriesSinkError(t *testing.T) {
+	t.Parallel()
+	emitted := make(chan int)
+	c := counter.NewCounter()
+	c.Delay = time.Hour
+	c.Sink = failingSink(2, emitted)
+	c.Retry = counter.RetryPolicy{Retries: 2, Backoff: time.Millisecond}
+	done := make(chan error)
+	go func() { done <- c.Run() }()
+	c.Step()
+	if got := <-emitted; got != 0 {
+		t.Errorf("want 0 emitted, got %v", got)
+	}
+	c.Stop <- true
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+type failingWriter struct {
+	failures int
+	lines    lineWriter
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if w.failures > 0 {
+		w.failures--
+		return 0, errors.New("write failed")
+	}
+	return w.lines.Write(p)
+}
+
+func TestRunRetriesOnlyFailedSinks(t *testing.T) {
+	t.Parallel()
+	failing := &failingWriter{failures: 2, lines: make(lineWriter, 10)}
+	healthy := make(lineWriter, 10)
+	c := counter.NewCounter()
+	c.Delay = time.Hour
+	c.Sink = counter.MultiSink(counter.TextSink(failing), counter.TextSink(healthy))
+	c.Retry = counter.RetryPolicy{Retries: 2, Backoff: time.Millisecond}
+	done := make(chan error)
+	go func() { done <- c.Run() }()
+	c.Step()
+	expectLine(t, failing.lines, "0\n")
+	stop(t, &c)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	expectLine(t, healthy, "0\n")
+	if len(healthy) != 0 {
+		t.Errorf("want one copy in the healthy sink, got %d more", len(healthy))
+	}
+}
+
+func TestRunStopsDuringRetryBackoff(t *testing.T) {
+	t.Parallel()
+	clock := newFakeClock(testTime)
+	c := counter.NewCounter()
+	c.Delay = time.Hour
+	c.Clock = clock
+	c.Sink = failingSink(1, make(chan int))
+	c.Retry = counter.RetryPolicy{Retries: 1, Backoff: time.Hour}
+	done := make(chan error)
+	go func() { done <- c.Run() }()
+	<-clock.waiting
+	c.Step()
+	// the backoff waits on the clock
+	<-clock.waiting
+	c.Stop <- true
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,160 @@
+package counter
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ID is a Snowflake-style identifier: 41 bits of milliseconds since the
+// generator's epoch, 10 bits of node and 12 bits of per-millisecond sequence.
+type ID int64
+
+const (
+	nodeBits     = 10
+	sequenceBits = 12
+	MaxNode      = 1<<nodeBits - 1
+	maxSequence  = 1<<sequenceBits - 1
+)
+
+var (
+	DefaultEpoch           = time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	ErrClockMovedBackwards = errors.New("clock moved backwards")
+)
+
+type IDGenerator struct {
+	Epoch   time.Time
+	Clock   Clock
+	MaxSkew time.Duration
+
+	node     int64
+	mu       sync.Mutex
+	last     int64
+	sequence int64
+}
+
+func NewIDGenerator(node int) (*IDGenerator, error) {
+	if node < 0 || node > MaxNode {
+		return nil, fmt.Errorf("node %d out of range 0-%d", node, MaxNode)
+	}
+	return &IDGenerator{
+		node:    int64(node),
+		Epoch:   DefaultEpoch,
+		MaxSkew: 10 * time.Millisecond,
+		last:    -1,
+	}, nil
+}
+
+func (g *IDGenerator) clock() Clock {
+	if g.Clock == nil {
+		return realClock{}
+	}
+	return g.Clock
+}
+
+func (g *IDGenerator) millis() int64 {
+	return g.clock().Now().Sub(g.Epoch).Milliseconds()
+}
+
+// waitPast blocks until the clock reads a later millisecond than last.
+func (g *IDGenerator) waitPast(last int64) int64 {
+	for {
+		now := g.millis()
+		if now > last {
+			return now
+		}
+		<-g.clock().After(time.Duration(last-now+1) * time.Millisecond)
+	}
+}
+
+// Next returns a new ID. A clock that moved backwards by up to MaxSkew is
+// waited out; a larger regression is reported as ErrClockMovedBackwards.
+func (g *IDGenerator) Next() (ID, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := g.millis()
+	if now < g.last {
+		if time.Duration(g.last-now)*time.Millisecond > g.MaxSkew {
+			return 0, fmt.Errorf("%w by %dms", ErrClockMovedBackwards, g.last-now)
+		}
+		now = g.waitPast(g.last - 1)
+	}
+	if now == g.last {
+		g.sequence = (g.sequence + 1) & maxSequence
+		if g.sequence == 0 {
+			now = g.waitPast(g.last)
+		}
+	} else {
+		g.sequence = 0
+	}
+	g.last = now
+	return ID(now<<(nodeBits+sequenceBits) | g.node<<sequenceBits | g.sequence), nil
+}
+
+func (g *IDGenerator) Time(id ID) time.Time {
+	return g.Epoch.Add(time.Duration(id>>(nodeBits+sequenceBits)) * time.Millisecond)
+}
+
+func (id ID) Node() int {
+	return int(id>>sequenceBits) & MaxNode
+}
+
+func (id ID) Sequence() int {
+	return int(id) & maxSequence
+}
+
+// Both alphabets are in ASCII order and encodings are zero-padded to a fixed
+// width, so encoded IDs sort the same way as the numbers.
+const (
+	base32Alphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+	base62Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	base32Width    = 13
+	base62Width    = 11
+)
+
+func (id ID) Base32() string {
+	return encode(uint64(id), base32Alphabet, base32Width)
+}
+
+func (id ID) Base62() string {
+	return encode(uint64(id), base62Alphabet, base62Width)
+}
+
+func ParseBase32(s string) (ID, error) {
+	return decode(strings.ToUpper(s), base32Alphabet, base32Width)
+}
+
+func ParseBase62(s string) (ID, error) {
+	return decode(s, base62Alphabet, base62Width)
+}
+
+func encode(value uint64, alphabet string, width int) string {
+	base := uint64(len(alphabet))
+	digits := make([]byte, width)
+	for i := width - 1; i >= 0; i-- {
+		digits[i] = alphabet[value%base]
+		value /= base
+	}
+	return string(digits)
+}
+
+func decode(s, alphabet string, width int) (ID, error) {
+	if len(s) != width {
+		return 0, fmt.Errorf("invalid ID %q: want %d digits", s, width)
+	}
+	base := uint64(len(alphabet))
+	var value uint64
+	for _, digit := range []byte(s) {
+		i := strings.IndexByte(alphabet, digit)
+		if i < 0 {
+			return 0, fmt.Errorf("invalid ID %q: bad digit %q", s, digit)
+		}
+		if value > (1<<63-1-uint64(i))/base {
+			return 0, fmt.Errorf("invalid ID %q: out of range", s)
+		}
+		value = value*base + uint64(i)
+	}
+	return ID(value), nil
+}
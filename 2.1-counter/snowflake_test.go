@@ -0,0 +1,174 @@
+package counter_test
+
+import (
+	"counter"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// steppingClock advances its own time instead of blocking in After.
+type steppingClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (clock *steppingClock) Now() time.Time {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	return clock.now
+}
+
+func (clock *steppingClock) After(d time.Duration) <-chan time.Time {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	clock.now = clock.now.Add(d)
+	c := make(chan time.Time, 1)
+	c <- clock.now
+	return c
+}
+
+func (clock *steppingClock) Set(now time.Time) {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	clock.now = now
+}
+
+func newTestGenerator(t *testing.T, node int, clock counter.Clock) *counter.IDGenerator {
+	t.Helper()
+	g, err := counter.NewIDGenerator(node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.Clock = clock
+	return g
+}
+
+func mustNext(t *testing.T, g *counter.IDGenerator) counter.ID {
+	t.Helper()
+	id, err := g.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestNewIDGeneratorNodeRange(t *testing.T) {
+	t.Parallel()
+	for _, node := range []int{-1, counter.MaxNode + 1} {
+		if _, err := counter.NewIDGenerator(node); err == nil {
+			t.Errorf("want error for node %d", node)
+		}
+	}
+}
+
+func TestIDFields(t *testing.T) {
+	t.Parallel()
+	now := counter.DefaultEpoch.Add(1234 * time.Millisecond)
+	g := newTestGenerator(t, 42, &steppingClock{now: now})
+	mustNext(t, g)
+	id := mustNext(t, g)
+	if got := id.Node(); got != 42 {
+		t.Errorf("want node 42, got %d", got)
+	}
+	if got := id.Sequence(); got != 1 {
+		t.Errorf("want sequence 1, got %d", got)
+	}
+	if got := g.Time(id); !got.Equal(now) {
+		t.Errorf("want time %v, got %v", now, got)
+	}
+}
+
+func TestIDSequenceExhaustion(t *testing.T) {
+	t.Parallel()
+	clock := &steppingClock{now: counter.DefaultEpoch}
+	g := newTestGenerator(t, 1, clock)
+	var last counter.ID = -1
+	for i := 0; i < 3*4096; i++ {
+		id := mustNext(t, g)
+		if id <= last {
+			t.Fatalf("IDs not increasing: %d after %d", id, last)
+		}
+		last = id
+	}
+	if got := clock.Now().Sub(counter.DefaultEpoch); got != 2*time.Millisecond {
+		t.Errorf("want clock advanced by 2ms, got %v", got)
+	}
+}
+
+func TestIDClockRegression(t *testing.T) {
+	t.Parallel()
+	start := counter.DefaultEpoch.Add(time.Second)
+	clock := &steppingClock{now: start}
+	g := newTestGenerator(t, 1, clock)
+	first := mustNext(t, g)
+	clock.Set(start.Add(-5 * time.Millisecond))
+	second := mustNext(t, g)
+	if second <= first {
+		t.Errorf("IDs not increasing after small regression: %d after %d", second, first)
+	}
+	clock.Set(start.Add(-time.Second))
+	_, err := g.Next()
+	if !errors.Is(err, counter.ErrClockMovedBackwards) {
+		t.Errorf("want ErrClockMovedBackwards, got %v", err)
+	}
+}
+
+func TestIDsUniqueAcrossGoroutines(t *testing.T) {
+	t.Parallel()
+	g := newTestGenerator(t, 7, nil)
+	var mu sync.Mutex
+	seen := map[counter.ID]bool{}
+	var wg sync.WaitGroup
+	for worker := 0; worker < 8; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				id, err := g.Next()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				mu.Lock()
+				if seen[id] {
+					t.Errorf("duplicate ID %d", id)
+				}
+				seen[id] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestIDEncodingsRoundTripAndSort(t *testing.T) {
+	t.Parallel()
+	ids := []counter.ID{0, 1, 61, 62, 1 << 40, 1<<62 + 12345, 1<<63 - 1}
+	var base32s, base62s []string
+	for _, id := range ids {
+		b32 := id.Base32()
+		if got, err := counter.ParseBase32(b32); err != nil || got != id {
+			t.Errorf("base32 %q: want %d, got %d (%v)", b32, id, got, err)
+		}
+		b62 := id.Base62()
+		if got, err := counter.ParseBase62(b62); err != nil || got != id {
+			t.Errorf("base62 %q: want %d, got %d (%v)", b62, id, got, err)
+		}
+		base32s = append(base32s, b32)
+		base62s = append(base62s, b62)
+	}
+	if !sort.StringsAreSorted(base32s) {
+		t.Errorf("base32 encodings not sorted: %v", base32s)
+	}
+	if !sort.StringsAreSorted(base62s) {
+		t.Errorf("base62 encodings not sorted: %v", base62s)
+	}
+	for _, bad := range []string{"", "0000000000001", "zzzzzzzzzzz", "0000000000-"} {
+		if _, err := counter.ParseBase62(bad); err == nil {
+			t.Errorf("want error parsing base62 %q", bad)
+		}
+	}
+}
@@ -1,10 +1,25 @@
 package main
 
 import (
+	"context"
 	"count"
 	"fmt"
+	"os"
+	"os/signal"
 )
 
 func main() {
-	fmt.Println(count.Lines())
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	c, err := count.NewCounter(
+		count.WithArgs(os.Args[1:]),
+		count.WithContext(ctx),
+	)
+	if err == nil {
+		err = c.Report()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 }
@@ -2,26 +2,68 @@ package count
 
 import (
 	"bufio"
+	"bytes"
+	"context"
 	"errors"
+	"fmt"
 	"io"
+	"io/fs"
 	"os"
+	"regexp"
+	"runtime"
 	"strings"
+	"time"
 )
 
 type counter struct {
-	input   io.Reader
-	output  io.Writer
-	pattern string
-	fold    func(string) string
+	input    io.Reader
+	output   io.Writer
+	patterns []string
+	// regexps tells which patterns were added by WithRegexp
+	regexps []bool
+	// fold appends the case folded form of a line to dst; nil leaves case alone
+	fold func(dst, line []byte) []byte
+	// regexp makes the other patterns regular expressions too, unless
+	// fixedStrings is set
+	regexp       bool
+	fixedStrings bool
+	ignoreCase   bool
+	wholeWord    bool
+	invert       bool
+	matcher      matcher
+	// maxLineLength caps the memory used for a single line; 0 means no cap
+	maxLineLength int
+	paths         []string
+	fsys          fs.FS
+	threshold     int
+	listing       listing
+	workers       int
+	chunkSize     int64
+	unique        uniqueMode
+	raw           bool
+	format        format
+	locale        string
+	follow        followMode
+	ctx           context.Context
 }
 
+var ErrLineTooLong = errors.New("line too long")
+
 type option func(*counter) error
 
 func NewCounter(opts ...option) (counter, error) {
 	c := counter{
-		input:  os.Stdin,
-		output: os.Stdout,
-		fold:   func(a string) string { return a },
+		input:     os.Stdin,
+		output:    os.Stdout,
+		fsys:      osFS{},
+		threshold: -1,
+		workers:   runtime.GOMAXPROCS(0),
+		chunkSize: defaultChunkSize,
+		follow: followMode{
+			window:       time.Minute,
+			pollInterval: 250 * time.Millisecond,
+		},
+		ctx: context.Background(),
 	}
 	for _, opt := range opts {
 		err := opt(&c)
@@ -29,7 +71,9 @@ func NewCounter(opts ...option) (counter, error) {
 			return counter{}, err
 		}
 	}
-	c.pattern = c.fold(c.pattern)
+	if err := c.compile(); err != nil {
+		return counter{}, err
+	}
 	return c, nil
 }
 
@@ -53,36 +97,327 @@ func WithOutput(output io.Writer) option {
 	}
 }
 
+// WithPattern adds a pattern; lines are counted per pattern, and in total when
+// they match any of them.
 func WithPattern(pattern string) option {
 	return func(c *counter) error {
-		c.pattern = pattern
+		c.addPattern(pattern, false)
+		return nil
+	}
+}
+
+// WithRegexp adds a pattern that is a regular expression, whatever the other
+// options.
+func WithRegexp(pattern string) option {
+	return func(c *counter) error {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return err
+		}
+		c.addPattern(pattern, true)
+		return nil
+	}
+}
+
+// WithPatternFile adds a pattern for each non-empty line of the file.
+func WithPatternFile(path string) option {
+	return func(c *counter) error {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		for _, pattern := range strings.Split(string(data), "\n") {
+			pattern = strings.TrimSuffix(pattern, "\r")
+			if pattern != "" {
+				c.addPattern(pattern, false)
+			}
+		}
+		return nil
+	}
+}
+
+// useRegexp makes the patterns of WithPattern and WithPatternFile regular
+// expressions, wherever it comes among the options.
+func useRegexp() option {
+	return func(c *counter) error {
+		c.regexp = true
 		return nil
 	}
 }
 
+// WithFixedStrings keeps the patterns of WithPattern and WithPatternFile fixed
+// strings. Patterns of WithRegexp stay regular expressions.
+func WithFixedStrings() option {
+	return func(c *counter) error {
+		c.fixedStrings = true
+		return nil
+	}
+}
+
+// wholeWordExpr only matches what expr does between non-word runes or the ends
+// of the line, which \b can't do as it only knows ASCII. The runes around the
+// match are consumed, which doesn't matter to telling whether a line matches.
+func wholeWordExpr(expr string) string {
+	return `(?:^|[^\pL\p{Nd}_])(?:` + expr + `)(?:[^\pL\p{Nd}_]|$)`
+}
+
+func (c *counter) addPattern(pattern string, isRegexp bool) {
+	c.patterns = append(c.patterns, pattern)
+	c.regexps = append(c.regexps, isRegexp)
+}
+
+// isRegexp reports whether pattern i is a regular expression.
+func (c counter) isRegexp(i int) bool {
+	return c.regexps[i] || c.regexp && !c.fixedStrings
+}
+
+// anyRegexp reports whether any pattern is a regular expression.
+func (c counter) anyRegexp() bool {
+	for i := range c.patterns {
+		if c.isRegexp(i) {
+			return true
+		}
+	}
+	return false
+}
+
+// IgnoreCase matches under full Unicode case folding. Regular expressions
+// match the folded line with their literals folded, and their character
+// classes under regexp's (?i), which only knows simple folding.
 func IgnoreCase() option {
 	return func(c *counter) error {
-		c.fold = strings.ToLower
+		c.fold = foldCase
+		c.ignoreCase = true
+		return nil
+	}
+}
+
+// IgnoreCaseIn is IgnoreCase with the dotted and dotless i rules of a locale;
+// only Turkish ("tr") and Azerbaijani ("az") differ from the default.
+func IgnoreCaseIn(locale string) option {
+	return func(c *counter) error {
+		c.locale = locale
+		switch locale {
+		case "tr", "az":
+			c.fold = foldCaseTurkish
+		case "", "und":
+			c.fold = foldCase
+		default:
+			return fmt.Errorf("unsupported locale %q", locale)
+		}
+		c.ignoreCase = true
+		return nil
+	}
+}
+
+// WholeWord only counts matches that are neither preceded nor followed by a
+// letter, a digit or an underscore.
+func WholeWord() option {
+	return func(c *counter) error {
+		c.wholeWord = true
+		return nil
+	}
+}
+
+func Invert() option {
+	return func(c *counter) error {
+		c.invert = true
 		return nil
 	}
 }
 
-func (c counter) Lines() int {
-	lines := 0
-	scanner := bufio.NewScanner(c.input)
-	for scanner.Scan() {
-		line := c.fold(scanner.Text())
-		if strings.Contains(line, c.pattern) {
-			lines++
+func WithMaxLineLength(length int) option {
+	return func(c *counter) error {
+		if length < 0 {
+			return errors.New("negative max line length")
+		}
+		c.maxLineLength = length
+		return nil
+	}
+}
+
+func (c *counter) compile() error {
+	if c.follow.enabled && c.format != textFormat {
+		return errors.New("follow mode only reports as text")
+	}
+	if c.unique.sketchWidth > 0 && c.unique.top == 0 {
+		return errors.New("approximate unique counts need a top limit")
+	}
+	if len(c.patterns) == 0 {
+		c.addPattern("", false)
+	}
+	var fixed, exprs []string
+	m := mixedMatcher{}
+	for i, pattern := range c.patterns {
+		if !c.isRegexp(i) {
+			fixed = append(fixed, pattern)
+			m.fixedIndex = append(m.fixedIndex, i)
+			continue
+		}
+		exprs = append(exprs, pattern)
+		m.regexpIndex = append(m.regexpIndex, i)
+	}
+	if len(exprs) == 0 {
+		c.matcher = newAhoCorasick(fixed, c.fold, c.wholeWord)
+		return nil
+	}
+	m.regexps.fold = c.fold
+	for _, expr := range exprs {
+		if c.fold != nil {
+			var err error
+			if expr, err = foldLiterals(expr, c.fold); err != nil {
+				return err
+			}
+		}
+		if c.wholeWord {
+			expr = wholeWordExpr(expr)
+		}
+		if c.ignoreCase {
+			expr = `(?i)` + expr
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return err
+		}
+		m.regexps.regexps = append(m.regexps.regexps, re)
+	}
+	if len(fixed) == 0 {
+		c.matcher = m.regexps
+		return nil
+	}
+	m.fixed = newAhoCorasick(fixed, c.fold, c.wholeWord)
+	c.matcher = m
+	return nil
+}
+
+type tally struct {
+	Lines    int
+	Matches  int
+	Patterns []int
+}
+
+func (c counter) newTally() tally {
+	return tally{Patterns: make([]int, len(c.patterns))}
+}
+
+func (t *tally) merge(other tally) {
+	t.Lines += other.Lines
+	t.Matches += other.Matches
+	for i, n := range other.Patterns {
+		t.Patterns[i] += n
+	}
+}
+
+// scratch holds per goroutine buffers, so that matching does not allocate.
+type scratch struct {
+	hits   []bool
+	folded []byte
+	// mixed holds the hits of the fixed patterns of a mixedMatcher
+	mixed []bool
+}
+
+func (c counter) newScratch() *scratch {
+	return &scratch{hits: make([]bool, len(c.patterns)), mixed: make([]bool, len(c.patterns))}
+}
+
+// matches reports whether line counts as a match, leaving per-pattern results
+// in s.hits.
+func (c counter) matches(line []byte, s *scratch) bool {
+	for i := range s.hits {
+		s.hits[i] = false
+	}
+	c.matcher.match(line, s)
+	matched := false
+	for _, hit := range s.hits {
+		matched = matched || hit
+	}
+	return matched != c.invert
+}
+
+func (c counter) add(t *tally, line []byte, s *scratch) {
+	t.Lines++
+	if c.matches(line, s) {
+		t.Matches++
+	}
+	for i, hit := range s.hits {
+		if hit != c.invert {
+			t.Patterns[i]++
+		}
+	}
+}
+
+func (c counter) count() (tally, error) {
+	input, err := c.open(c.input)
+	if err != nil {
+		return c.newTally(), err
+	}
+	t, err := c.scan(input)
+	if err == ErrLineTooLong {
+		err = fmt.Errorf("line %d: %w", t.Lines+1, err)
+	}
+	return t, err
+}
+
+// scan counts the lines of input; on error, the tally covers the lines before
+// the failing one.
+func (c counter) scan(input io.Reader) (tally, error) {
+	t := c.newTally()
+	s := c.newScratch()
+	err := c.eachLine(input, func(line []byte) {
+		c.add(&t, line, s)
+	})
+	return t, err
+}
+
+// eachLine calls f with every line of input; line is only valid during the
+// call.
+func (c counter) eachLine(input io.Reader, f func(line []byte)) error {
+	reader := bufio.NewReader(input)
+	var buf []byte
+	for {
+		line, err := c.readLine(reader, buf[:0])
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if err == io.EOF && len(line) == 0 {
+			return nil
+		}
+		f(line)
+		if err == io.EOF {
+			return nil
+		}
+		buf = line
+	}
+}
+
+func (c counter) Lines() (int, error) {
+	t, err := c.count()
+	return t.Matches, err
+}
+
+// readLine appends the next line, without its line ending, to buf.
+func (c counter) readLine(reader *bufio.Reader, buf []byte) ([]byte, error) {
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		buf = append(buf, chunk...)
+		if err == bufio.ErrBufferFull {
+			if c.maxLineLength > 0 && len(buf) > c.maxLineLength {
+				return nil, ErrLineTooLong
+			}
+			continue
+		}
+		buf = bytes.TrimSuffix(buf, []byte("\n"))
+		buf = bytes.TrimSuffix(buf, []byte("\r"))
+		if c.maxLineLength > 0 && len(buf) > c.maxLineLength {
+			return nil, ErrLineTooLong
 		}
+		return buf, err
 	}
-	return lines
 }
 
-func Lines() int {
+func Lines() (int, error) {
 	c, err := NewCounter()
 	if err != nil {
-		panic("internal error")
+		return 0, err
 	}
 	return c.Lines()
 }
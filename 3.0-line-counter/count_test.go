@@ -3,6 +3,14 @@ package count_test
 import (
 	"bytes"
 	"count"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 )
 
@@ -16,7 +24,10 @@ func TestLines(t *testing.T) {
 		t.Fatal(err)
 	}
 	want := 3
-	got := c.Lines()
+	got, err := c.Lines()
+	if err != nil {
+		t.Fatal(err)
+	}
 	if want != got {
 		t.Errorf("want %d, got %d", want, got)
 	}
@@ -33,7 +44,10 @@ func TestMatches(t *testing.T) {
 		t.Fatal(err)
 	}
 	want := 3
-	got := c.Lines()
+	got, err := c.Lines()
+	if err != nil {
+		t.Fatal(err)
+	}
 	if want != got {
 		t.Errorf("want %d, got %d", want, got)
 	}
@@ -51,8 +65,358 @@ func TestInsensitiveMatches(t *testing.T) {
 		t.Fatal(err)
 	}
 	want := 4
-	got := c.Lines()
+	got, err := c.Lines()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want != got {
+		t.Errorf("want %d, got %d", want, got)
+	}
+}
+
+const matchInput = "foo\nfood\nFoo bar\nbar\nerror 42\nerror x\n"
+
+func assertLines(t *testing.T, c interface{ Lines() (int, error) }, err error, want int) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.Lines()
+	if err != nil {
+		t.Fatal(err)
+	}
 	if want != got {
 		t.Errorf("want %d, got %d", want, got)
 	}
 }
+
+func TestFixedStrings(t *testing.T) {
+	t.Parallel()
+	c, err := count.NewCounter(
+		count.WithInput(bytes.NewBufferString(matchInput)),
+		count.WithPattern("o."),
+		count.WithFixedStrings(),
+	)
+	assertLines(t, c, err, 0)
+}
+
+func TestFixedStringsLeaveRegexps(t *testing.T) {
+	t.Parallel()
+	c, err := count.NewCounter(
+		count.WithInput(bytes.NewBufferString(matchInput)),
+		count.WithRegexp("o."),
+		count.WithFixedStrings(),
+	)
+	assertLines(t, c, err, 5)
+}
+
+func TestFixedStringsAndRegexps(t *testing.T) {
+	t.Parallel()
+	type reporter interface{ Report() error }
+	tests := []struct {
+		name  string
+		build func(input io.Reader, output io.Writer) (reporter, error)
+		want  string
+	}{
+		{"fixed string first", func(input io.Reader, output io.Writer) (reporter, error) {
+			return count.NewCounter(count.WithInput(input), count.WithOutput(output), count.WithPattern("a.c"), count.WithRegexp("x+"))
+		}, " 1 a.c\n 1 x+\n"},
+		{"regexp first", func(input io.Reader, output io.Writer) (reporter, error) {
+			return count.NewCounter(count.WithInput(input), count.WithOutput(output), count.WithRegexp("x+"), count.WithPattern("a.c"))
+		}, " 1 x+\n 1 a.c\n"},
+	}
+	for _, tt := range tests {
+		output := &bytes.Buffer{}
+		c, err := tt.build(strings.NewReader("a.c\nabc\nxx\n"), output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Report(); err != nil {
+			t.Fatal(err)
+		}
+		if got := output.String(); got != tt.want {
+			t.Errorf("%s: want %q, got %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestRegexp(t *testing.T) {
+	t.Parallel()
+	c, err := count.NewCounter(
+		count.WithInput(bytes.NewBufferString(matchInput)),
+		count.WithRegexp(`error \d+`),
+	)
+	assertLines(t, c, err, 1)
+}
+
+func TestInsensitiveRegexp(t *testing.T) {
+	t.Parallel()
+	c, err := count.NewCounter(
+		count.WithInput(bytes.NewBufferString(matchInput)),
+		count.WithRegexp(`^FO+\b`),
+		count.IgnoreCase(),
+	)
+	assertLines(t, c, err, 2)
+}
+
+func TestInvalidRegexp(t *testing.T) {
+	t.Parallel()
+	_, err := count.NewCounter(count.WithRegexp("a("))
+	if err == nil {
+		t.Error("want error for invalid regexp")
+	}
+}
+
+func TestWholeWord(t *testing.T) {
+	t.Parallel()
+	c, err := count.NewCounter(
+		count.WithInput(bytes.NewBufferString(matchInput)),
+		count.WithPattern("foo"),
+		count.WholeWord(),
+	)
+	assertLines(t, c, err, 1)
+}
+
+func TestUnicodeWholeWord(t *testing.T) {
+	t.Parallel()
+	input := "café au lait\ncafés\nun café\nécafé\n"
+	c, err := count.NewCounter(
+		count.WithInput(strings.NewReader(input)),
+		count.WithPattern("café"),
+		count.WholeWord(),
+	)
+	assertLines(t, c, err, 2)
+	c, err = count.NewCounter(
+		count.WithInput(strings.NewReader(input)),
+		count.WithRegexp("caf."),
+		count.WholeWord(),
+	)
+	assertLines(t, c, err, 2)
+}
+
+func TestInsensitiveWholeWord(t *testing.T) {
+	t.Parallel()
+	c, err := count.NewCounter(
+		count.WithInput(bytes.NewBufferString(matchInput)),
+		count.WithPattern("foo"),
+		count.WholeWord(),
+		count.IgnoreCase(),
+	)
+	assertLines(t, c, err, 2)
+}
+
+func TestInvert(t *testing.T) {
+	t.Parallel()
+	c, err := count.NewCounter(
+		count.WithInput(bytes.NewBufferString(matchInput)),
+		count.WithPattern("foo"),
+		count.Invert(),
+	)
+	assertLines(t, c, err, 4)
+}
+
+func TestInvertRegexp(t *testing.T) {
+	t.Parallel()
+	c, err := count.NewCounter(
+		count.WithInput(bytes.NewBufferString(matchInput)),
+		count.WithRegexp(`^error`),
+		count.Invert(),
+	)
+	assertLines(t, c, err, 4)
+}
+
+func TestLongLines(t *testing.T) {
+	t.Parallel()
+	long := strings.Repeat("x", 1<<20)
+	input := "short\n" + long + "A\n" + long + "\nA"
+	c, err := count.NewCounter(
+		count.WithInput(strings.NewReader(input)),
+		count.WithPattern("A"),
+	)
+	assertLines(t, c, err, 2)
+}
+
+func TestMaxLineLength(t *testing.T) {
+	t.Parallel()
+	input := "short\n" + strings.Repeat("x", 1<<17) + "\nshort\n"
+	c, err := count.NewCounter(
+		count.WithInput(strings.NewReader(input)),
+		count.WithMaxLineLength(1<<16),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.Lines()
+	if !errors.Is(err, count.ErrLineTooLong) {
+		t.Errorf("want ErrLineTooLong, got %v", err)
+	}
+}
+
+func TestCRLFLines(t *testing.T) {
+	t.Parallel()
+	c, err := count.NewCounter(
+		count.WithInput(strings.NewReader("a\r\nb\r\n")),
+		count.WithRegexp("^[ab]$"),
+	)
+	assertLines(t, c, err, 2)
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("read failed")
+}
+
+func TestReadError(t *testing.T) {
+	t.Parallel()
+	c, err := count.NewCounter(
+		count.WithInput(io.MultiReader(strings.NewReader("a\n"), failingReader{})),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.Lines()
+	if err == nil {
+		t.Error("want read error")
+	}
+}
+
+func TestMultiplePatterns(t *testing.T) {
+	t.Parallel()
+	input := "E1 E2\nE2\nE3\nok\nE10\n"
+	c, err := count.NewCounter(
+		count.WithInput(strings.NewReader(input)),
+		count.WithPattern("E1"),
+		count.WithPattern("E2"),
+		count.WithPattern("E4"),
+	)
+	assertLines(t, c, err, 3)
+}
+
+func TestMultiplePatternsInvert(t *testing.T) {
+	t.Parallel()
+	input := "E1 E2\nE2\nE3\nok\nE10\n"
+	c, err := count.NewCounter(
+		count.WithInput(strings.NewReader(input)),
+		count.WithPattern("E1"),
+		count.WithPattern("E2"),
+		count.Invert(),
+	)
+	assertLines(t, c, err, 2)
+}
+
+func TestMultiplePatternsWholeWord(t *testing.T) {
+	t.Parallel()
+	input := "E1 E2\nE2\nE3\nok\nE10\nxE1\nE1x E1\n"
+	c, err := count.NewCounter(
+		count.WithInput(strings.NewReader(input)),
+		count.WithPattern("E1"),
+		count.WithPattern("ok"),
+		count.WholeWord(),
+	)
+	assertLines(t, c, err, 3)
+}
+
+func TestOverlappingPatterns(t *testing.T) {
+	t.Parallel()
+	input := "she\nhis\nhers\nushers\nxyz\n"
+	output := &bytes.Buffer{}
+	c, err := count.NewCounter(
+		count.WithInput(strings.NewReader(input)),
+		count.WithOutput(output),
+		count.WithPattern("he"),
+		count.WithPattern("she"),
+		count.WithPattern("his"),
+		count.WithPattern("hers"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Report(); err != nil {
+		t.Fatal(err)
+	}
+	want := " 3 he\n 2 she\n 1 his\n 2 hers\n"
+	got := output.String()
+	if want != got {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
+
+func TestPatternFile(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "patterns")
+	if err := os.WriteFile(path, []byte("E1\r\n\nE3\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := count.NewCounter(
+		count.WithInput(strings.NewReader("E1\nE2\nE3\n")),
+		count.WithPatternFile(path),
+	)
+	assertLines(t, c, err, 2)
+}
+
+// writePatternFile writes n random lowercase patterns of 20 to 30 bytes, one
+// per line, about as many bytes as a 20k line pattern file has.
+func writePatternFile(tb testing.TB, n int) (string, []string) {
+	tb.Helper()
+	random := rand.New(rand.NewSource(1))
+	patterns := make([]string, n)
+	var data strings.Builder
+	for i := range patterns {
+		pattern := make([]byte, 20+random.Intn(11))
+		for j := range pattern {
+			pattern[j] = byte('a' + random.Intn(26))
+		}
+		patterns[i] = string(pattern)
+		data.WriteString(patterns[i] + "\n")
+	}
+	path := filepath.Join(tb.TempDir(), "patterns")
+	if err := os.WriteFile(path, []byte(data.String()), 0o644); err != nil {
+		tb.Fatal(err)
+	}
+	return path, patterns
+}
+
+// TestLargePatternSet isn't parallel, so that it measures the memory taken
+// by its own matcher.
+func TestLargePatternSet(t *testing.T) {
+	path, patterns := writePatternFile(t, 20000)
+	input := "nothing to see\nsome " + patterns[7] + " here\n" + patterns[19999] + "\n"
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	c, err := count.NewCounter(
+		count.WithInput(strings.NewReader(input)),
+		count.WithPatternFile(path),
+	)
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	assertLines(t, c, err, 2)
+	if used := after.HeapAlloc - before.HeapAlloc; used > 64<<20 {
+		t.Errorf("want the matcher to take under 64 MiB, took %d MiB", used>>20)
+	}
+}
+
+func BenchmarkLargePatternSet(b *testing.B) {
+	path, patterns := writePatternFile(b, 20000)
+	var input strings.Builder
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&input, "line %d of some log text without any of the patterns\n", i)
+		if i%100 == 0 {
+			input.WriteString(patterns[i] + "\n")
+		}
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c, err := count.NewCounter(
+			count.WithInput(strings.NewReader(input.String())),
+			count.WithPatternFile(path),
+		)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := c.Lines(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
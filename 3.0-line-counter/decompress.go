@@ -0,0 +1,92 @@
+package count
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+)
+
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	bzip2Magic = []byte("BZh")
+)
+
+// zlibProbe is how much of an input with a zlib header must inflate cleanly
+// for it to be taken as zlib data.
+const zlibProbe = 1 << 10
+
+// RawInput counts compressed input as it is, instead of decompressing it.
+func RawInput() option {
+	return func(c *counter) error {
+		c.raw = true
+		return nil
+	}
+}
+
+// decompress looks at the magic bytes at the start of input and, for gzip,
+// bzip2 and zlib data, returns a reader of the decompressed stream.
+func decompress(input io.Reader) (io.Reader, error) {
+	buffered := bufio.NewReader(input)
+	magic, err := buffered.Peek(4)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		return gzip.NewReader(buffered)
+	case isBzip2(magic):
+		return bzip2.NewReader(buffered), nil
+	case isZlibHeader(magic):
+		head, err := buffered.Peek(zlibProbe)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if inflates(head) {
+			return zlib.NewReader(buffered)
+		}
+	}
+	return buffered, nil
+}
+
+// isCompressed reports whether an input starting with head, of up to
+// zlibProbe bytes, is decompressed.
+func isCompressed(head []byte) bool {
+	return bytes.HasPrefix(head, gzipMagic) || isBzip2(head) || isZlibHeader(head) && inflates(head)
+}
+
+func isBzip2(magic []byte) bool {
+	return len(magic) >= 4 && bytes.HasPrefix(magic, bzip2Magic) && '1' <= magic[3] && magic[3] <= '9'
+}
+
+// isZlibHeader reports whether magic starts with a valid zlib header, without
+// a preset dictionary. Plain text such as "x^2" passes, so the data after it
+// has to be checked with inflates.
+func isZlibHeader(magic []byte) bool {
+	if len(magic) < 2 {
+		return false
+	}
+	cmf, flg := magic[0], magic[1]
+	return cmf&0x0f == 8 && cmf>>4 <= 7 && flg&0x20 == 0 && (uint(cmf)<<8|uint(flg))%31 == 0
+}
+
+// inflates reports whether head is the start of a zlib stream, inflating
+// without error up to its end or that of the stream.
+func inflates(head []byte) bool {
+	r, err := zlib.NewReader(bytes.NewReader(head))
+	if err != nil {
+		return false
+	}
+	_, err = io.Copy(io.Discard, r)
+	return err == nil || err == io.ErrUnexpectedEOF
+}
+
+// open prepares input for counting, decompressing it unless RawInput is set.
+func (c counter) open(input io.Reader) (io.Reader, error) {
+	if c.raw {
+		return input, nil
+	}
+	return decompress(input)
+}
@@ -0,0 +1,131 @@
+package count_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"count"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+const plainLog = "error: disk full\nok\nerror: timeout\nok\nok\n"
+
+func gzipped(t *testing.T, data string) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	w := gzip.NewWriter(buf)
+	if _, err := w.Write([]byte(data)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func zlibbed(t *testing.T, data string, level int) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	w, err := zlib.NewWriterLevel(buf, level)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte(data)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecompressInput(t *testing.T) {
+	t.Parallel()
+	bzipped, err := os.ReadFile("testdata/app.log.bz2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	inputs := map[string][]byte{
+		"plain":      []byte(plainLog),
+		"gzip":       gzipped(t, plainLog),
+		"bzip2":      bzipped,
+		"zlib":       zlibbed(t, plainLog, zlib.DefaultCompression),
+		"zlib min":   zlibbed(t, plainLog, zlib.BestSpeed),
+		"zlib max":   zlibbed(t, plainLog, zlib.BestCompression),
+		"BZh text":   []byte("BZh error\nok\n"),
+		"x^ text":    []byte("x error\n"),
+		"x^2 text":   []byte("x^2 + y^2\nerror\n"),
+		"x\x01 text": []byte("x\x01 error\n"),
+	}
+	wants := map[string]int{"BZh text": 1, "x^ text": 1, "x^2 text": 1, "x\x01 text": 1}
+	for name, input := range inputs {
+		want, ok := wants[name]
+		if !ok {
+			want = 2
+		}
+		c, err := count.NewCounter(
+			count.WithInput(bytes.NewReader(input)),
+			count.WithPattern("error"),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := c.Lines()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if want != got {
+			t.Errorf("%s: want %d, got %d", name, want, got)
+		}
+	}
+}
+
+func TestRawInput(t *testing.T) {
+	t.Parallel()
+	c, err := count.NewCounter(
+		count.WithInput(bytes.NewReader(gzipped(t, strings.Repeat(plainLog, 100)))),
+		count.WithPattern("error"),
+		count.RawInput(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.Lines()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got >= 200 {
+		t.Errorf("want compressed bytes counted as they are, got %d matches", got)
+	}
+}
+
+func TestReportCompressedFiles(t *testing.T) {
+	t.Parallel()
+	fsys := fstest.MapFS{
+		"app.log":      {Data: []byte(plainLog)},
+		"app.log.1.gz": {Data: gzipped(t, plainLog+plainLog)},
+	}
+	output := &bytes.Buffer{}
+	c, err := count.NewCounter(
+		count.WithFS(fsys),
+		count.WithOutput(output),
+		count.WithPattern("error"),
+		count.WithPaths("app.log", "app.log.1.gz"),
+		count.WithChunkSize(8),
+		count.WithWorkers(4),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Report(); err != nil {
+		t.Fatal(err)
+	}
+	want := "2 app.log\n4 app.log.1.gz\n6 total\n"
+	got := output.String()
+	if want != got {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
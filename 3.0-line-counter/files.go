@@ -0,0 +1,455 @@
+package count
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const stdin = "-"
+
+type listing int
+
+const (
+	allFiles listing = iota
+	filesWithMatches
+	filesWithoutMatch
+)
+
+type FileCount struct {
+	Path  string
+	Count int
+	// Lines is the number of lines scanned
+	Lines int
+	// Patterns holds the number of matching lines per pattern
+	Patterns []int
+}
+
+type patternList []string
+
+func (list *patternList) String() string {
+	return strings.Join(*list, ",")
+}
+
+func (list *patternList) Set(pattern string) error {
+	*list = append(*list, pattern)
+	return nil
+}
+
+// osFS opens names as given by the user, including absolute and parent paths
+// that os.DirFS would reject.
+type osFS struct{}
+
+func (osFS) Open(name string) (fs.File, error) {
+	return os.Open(name)
+}
+
+func (osFS) Glob(pattern string) ([]string, error) {
+	return filepath.Glob(pattern)
+}
+
+func WithPaths(paths ...string) option {
+	return func(c *counter) error {
+		c.paths = paths
+		return nil
+	}
+}
+
+func WithFS(fsys fs.FS) option {
+	return func(c *counter) error {
+		if fsys == nil {
+			return fmt.Errorf("nil file system")
+		}
+		c.fsys = fsys
+		return nil
+	}
+}
+
+// Above limits the report to files with more than threshold matches.
+func Above(threshold int) option {
+	return func(c *counter) error {
+		c.threshold = threshold
+		return nil
+	}
+}
+
+func FilesWithMatches() option {
+	return func(c *counter) error {
+		c.listing = filesWithMatches
+		return nil
+	}
+}
+
+func FilesWithoutMatch() option {
+	return func(c *counter) error {
+		c.listing = filesWithoutMatch
+		return nil
+	}
+}
+
+func WithArgs(args []string) option {
+	return func(c *counter) error {
+		fset := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+		var patterns patternList
+		fset.Var(&patterns, "e", "count lines containing `pattern`; may be repeated")
+		patternFile := fset.String("f", "", "read patterns from `file`, one per line")
+		regexp := fset.Bool("E", false, "treat the pattern as a regular expression")
+		ignoreCase := fset.Bool("i", false, "ignore case")
+		locale := fset.String("locale", "", "with -i, fold case by the rules of `locale`, like tr")
+		wholeWord := fset.Bool("w", false, "match whole words only")
+		invert := fset.Bool("v", false, "count lines that do not match")
+		maxLineLength := fset.Int("max-line-length", 0, "fail on lines longer than `bytes`; 0 means no limit")
+		workers := fset.Int("workers", runtime.GOMAXPROCS(0), "count large files with `n` goroutines")
+		above := fset.Int("above", -1, "only report files with more than `n` matches")
+		withMatches := fset.Bool("l", false, "only print names of files with more than -above matches")
+		withoutMatch := fset.Bool("L", false, "only print names of files without matches")
+		unique := fset.Bool("u", false, "count occurrences of each distinct matching line")
+		top := fset.Int("top", 0, "with -u, only report the `n` most frequent lines")
+		minCount := fset.Int("min", 0, "with -u, only report lines occurring at least `n` times")
+		sortBy := fset.String("sort", "freq", "with -u, sort lines by `order`: freq or lex")
+		collapseSpace := fset.Bool("collapse-space", false, "with -u, collapse runs of white space")
+		maskDigits := fset.Bool("mask-digits", false, "with -u, replace numbers by #")
+		maskUUIDs := fset.Bool("mask-uuids", false, "with -u, replace UUIDs by <uuid>")
+		approximate := fset.Bool("approx", false, "with -u and -top, count in bounded memory, approximately")
+		format := fset.String("format", "text", "print results as `format`: text, json or csv")
+		raw := fset.Bool("raw", false, "count compressed files as they are, without decompressing them")
+		follow := fset.Bool("follow", false, "follow the growth of a single file, reporting every -interval")
+		interval := fset.Duration("interval", 5*time.Second, "with -follow, report every `duration`")
+		window := fset.Duration("window", time.Minute, "with -follow, compute the rate over `duration`")
+		if err := fset.Parse(args); err != nil {
+			return err
+		}
+		opts := []option{WithPaths(fset.Args()...)}
+		for _, pattern := range patterns {
+			opts = append(opts, WithPattern(pattern))
+		}
+		if *patternFile != "" {
+			opts = append(opts, WithPatternFile(*patternFile))
+		}
+		if *regexp {
+			opts = append(opts, useRegexp())
+		}
+		if *ignoreCase {
+			opts = append(opts, IgnoreCaseIn(*locale))
+		}
+		if *wholeWord {
+			opts = append(opts, WholeWord())
+		}
+		if *invert {
+			opts = append(opts, Invert())
+		}
+		opts = append(opts, WithMaxLineLength(*maxLineLength), WithWorkers(*workers), Above(*above))
+		if *withMatches {
+			opts = append(opts, FilesWithMatches())
+			if *above < 0 {
+				opts = append(opts, Above(0))
+			}
+		}
+		if *withoutMatch {
+			opts = append(opts, FilesWithoutMatch())
+		}
+		if *unique {
+			opts = append(opts, Unique(), MinCount(*minCount))
+		}
+		if *top > 0 {
+			opts = append(opts, Top(*top))
+		}
+		switch *sortBy {
+		case "freq":
+		case "lex":
+			opts = append(opts, SortLexically())
+		default:
+			return fmt.Errorf("unknown sort order %q", *sortBy)
+		}
+		if *collapseSpace {
+			opts = append(opts, CollapseSpace())
+		}
+		if *maskUUIDs {
+			opts = append(opts, MaskUUIDs())
+		}
+		if *maskDigits {
+			opts = append(opts, MaskDigits())
+		}
+		if *approximate {
+			opts = append(opts, Approximate(1<<20, 4))
+		}
+		opts = append(opts, WithFormat(*format))
+		if *raw {
+			opts = append(opts, RawInput())
+		}
+		if *follow {
+			opts = append(opts, Follow(*interval), RateWindow(*window))
+		}
+		for _, opt := range opts {
+			if err := opt(c); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// FilesError holds the errors met with some of the paths of a report, the
+// other files being counted all the same.
+type FilesError []error
+
+func (errs FilesError) Error() string {
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Is tells whether any of the errors is target.
+func (errs FilesError) Is(target error) bool {
+	for _, err := range errs {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first of the errors that matches target.
+func (errs FilesError) As(target interface{}) bool {
+	for _, err := range errs {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// expand resolves globs and walks directories, returning the files to count,
+// and the errors met with the paths it couldn't resolve or walk.
+func (c counter) expand() ([]string, FilesError) {
+	var files []string
+	var errs FilesError
+	for _, path := range c.paths {
+		if path == stdin {
+			files = append(files, path)
+			continue
+		}
+		matches := []string{path}
+		if strings.ContainsAny(path, `*?[\`) {
+			var err error
+			matches, err = fs.Glob(c.fsys, path)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if len(matches) == 0 {
+				errs = append(errs, fmt.Errorf("%s: no matching files", path))
+				continue
+			}
+		}
+		for _, match := range matches {
+			fs.WalkDir(c.fsys, match, func(p string, d fs.DirEntry, err error) error {
+				if err != nil {
+					// skip what can't be read, and go on with the rest
+					errs = append(errs, err)
+					return nil
+				}
+				if d.Type().IsRegular() || (p == match && !d.IsDir()) {
+					files = append(files, p)
+				}
+				return nil
+			})
+		}
+	}
+	return files, errs
+}
+
+func (c counter) countFile(path string) (tally, error) {
+	if path == stdin {
+		return c.count()
+	}
+	file, err := c.fsys.Open(path)
+	if err != nil {
+		return tally{}, err
+	}
+	defer file.Close()
+	var t tally
+	if size, ok := c.parallelizable(file); ok {
+		t, err = c.countChunks(file.(io.ReaderAt), size)
+	} else {
+		c.input = file
+		t, err = c.count()
+	}
+	if err != nil {
+		return t, fmt.Errorf("%s: %w", path, err)
+	}
+	return t, nil
+}
+
+// parallelizable reports whether file is an uncompressed regular file large
+// enough to be split among several workers, and its size.
+func (c counter) parallelizable(file fs.File) (int64, bool) {
+	readerAt, ok := file.(io.ReaderAt)
+	if !ok || c.workers < 2 {
+		return 0, false
+	}
+	info, err := file.Stat()
+	if err != nil || !info.Mode().IsRegular() || info.Size() < 2*c.chunkSize {
+		return 0, false
+	}
+	head := make([]byte, zlibProbe)
+	n, _ := readerAt.ReadAt(head, 0)
+	if !c.raw && isCompressed(head[:n]) {
+		return 0, false
+	}
+	return info.Size(), true
+}
+
+// Files counts each file, going on past the paths that can't be counted. Their
+// errors are returned as a FilesError, along with the counts of the others.
+func (c counter) Files() ([]FileCount, error) {
+	paths, errs := c.expand()
+	counts := make([]FileCount, 0, len(paths))
+	for _, path := range paths {
+		t, err := c.countFile(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		counts = append(counts, FileCount{Path: path, Count: t.Matches, Lines: t.Lines, Patterns: t.Patterns})
+	}
+	if len(errs) > 0 {
+		return counts, errs
+	}
+	return counts, nil
+}
+
+func (c counter) Report() error {
+	if c.follow.enabled {
+		return c.followFile()
+	}
+	if c.unique.enabled {
+		return c.printFrequencies()
+	}
+	start := time.Now()
+	var counts []FileCount
+	// the files that were counted are reported before the errors of the others
+	var filesErr error
+	if len(c.paths) == 0 {
+		t, err := c.count()
+		if err != nil {
+			return err
+		}
+		counts = []FileCount{{Path: stdin, Count: t.Matches, Lines: t.Lines, Patterns: t.Patterns}}
+	} else {
+		counts, filesErr = c.Files()
+	}
+	// like wc, a total follows files that couldn't be counted too
+	several := len(counts) > 1 || len(counts) > 0 && filesErr != nil
+	if err := c.printReport(counts, several, time.Since(start)); err != nil {
+		return err
+	}
+	return filesErr
+}
+
+// printReport prints the counts, followed by their total if there are
+// several files.
+func (c counter) printReport(counts []FileCount, several bool, elapsed time.Duration) error {
+	switch c.format {
+	case jsonFormat:
+		return c.printJSON(counts, elapsed)
+	case csvFormat:
+		return c.printCSV(counts, elapsed)
+	}
+	if len(c.paths) == 0 {
+		if len(c.patterns) > 1 {
+			return c.printTable(counts, false)
+		}
+		_, err := fmt.Fprintln(c.output, counts[0].Count)
+		return err
+	}
+	total := c.total(counts)
+	var rows []FileCount
+	for _, fc := range counts {
+		switch c.listing {
+		case filesWithoutMatch:
+			if fc.Count == 0 {
+				rows = append(rows, fc)
+			}
+		default:
+			if fc.Count > c.threshold {
+				rows = append(rows, fc)
+			}
+		}
+	}
+	if c.listing != allFiles {
+		for _, row := range rows {
+			if _, err := fmt.Fprintln(c.output, row.Path); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if several {
+		rows = append(rows, total)
+	}
+	if len(c.patterns) > 1 {
+		return c.printTable(rows, true)
+	}
+	return c.printCounts(rows)
+}
+
+func (c counter) total(counts []FileCount) FileCount {
+	total := FileCount{Path: "total", Patterns: make([]int, len(c.patterns))}
+	for _, fc := range counts {
+		total.Count += fc.Count
+		total.Lines += fc.Lines
+		for i, n := range fc.Patterns {
+			total.Patterns[i] += n
+		}
+	}
+	return total
+}
+
+func (c counter) printCounts(rows []FileCount) error {
+	width := 1
+	for _, row := range rows {
+		if w := len(strconv.Itoa(row.Count)); w > width {
+			width = w
+		}
+	}
+	for _, row := range rows {
+		if _, err := fmt.Fprintf(c.output, "%*d %s\n", width, row.Count, row.Path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// printTable prints a row per pattern and a column per file, under a header of
+// file names if asked to. Without files, it prints nothing, as printCounts.
+func (c counter) printTable(files []FileCount, header bool) error {
+	if len(files) == 0 {
+		return nil
+	}
+	table := tabwriter.NewWriter(c.output, 0, 0, 1, ' ', tabwriter.AlignRight)
+	if header {
+		for _, file := range files {
+			fmt.Fprintf(table, "%s\t", file.Path)
+		}
+		fmt.Fprintln(table)
+	}
+	for i, pattern := range c.patterns {
+		for _, file := range files {
+			fmt.Fprintf(table, "%d\t", file.Patterns[i])
+		}
+		fmt.Fprintf(table, " %s\n", pattern)
+	}
+	return table.Flush()
+}
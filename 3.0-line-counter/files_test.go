@@ -0,0 +1,164 @@
+package count_test
+
+import (
+	"bytes"
+	"count"
+	"errors"
+	"io/fs"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+var testFS = fstest.MapFS{
+	"a.log":          {Data: []byte("error\nok\nerror\n")},
+	"b.log":          {Data: []byte("ok\n")},
+	"notes.txt":      {Data: []byte("error\n")},
+	"sub/c.log":      {Data: []byte("error\nerror\nerror\n")},
+	"sub/deep/d.log": {Data: []byte("")},
+}
+
+func TestFiles(t *testing.T) {
+	t.Parallel()
+	c, err := count.NewCounter(
+		count.WithFS(testFS),
+		count.WithPattern("error"),
+		count.WithPaths("*.log", "sub"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.Files()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []count.FileCount{
+		{Path: "a.log", Count: 2, Lines: 3, Patterns: []int{2}},
+		{Path: "b.log", Count: 0, Lines: 1, Patterns: []int{0}},
+		{Path: "sub/c.log", Count: 3, Lines: 3, Patterns: []int{3}},
+		{Path: "sub/deep/d.log", Count: 0, Lines: 0, Patterns: []int{0}},
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
+
+func TestFilesMissing(t *testing.T) {
+	t.Parallel()
+	for _, path := range []string{"missing.log", "*.missing"} {
+		c, err := count.NewCounter(
+			count.WithFS(testFS),
+			count.WithPaths(path),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.Files(); err == nil {
+			t.Errorf("want error for %q", path)
+		}
+	}
+}
+
+func report(t *testing.T, args ...string) string {
+	t.Helper()
+	output := &bytes.Buffer{}
+	c, err := count.NewCounter(
+		count.WithFS(testFS),
+		count.WithOutput(output),
+		count.WithArgs(args),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Report(); err != nil {
+		t.Fatal(err)
+	}
+	return output.String()
+}
+
+func TestReport(t *testing.T) {
+	t.Parallel()
+	want := "2 a.log\n0 b.log\n1 notes.txt\n3 total\n"
+	got := report(t, "-e", "error", "a.log", "b.log", "notes.txt")
+	if want != got {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
+
+func TestReportGoesOnPastErrors(t *testing.T) {
+	t.Parallel()
+	output := &bytes.Buffer{}
+	c, err := count.NewCounter(
+		count.WithFS(testFS),
+		count.WithOutput(output),
+		count.WithArgs([]string{"-e", "error", "a.log", "missing.log", "*.missing"}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.Report()
+	var pathErr *fs.PathError
+	if !errors.As(err, &pathErr) || pathErr.Path != "missing.log" {
+		t.Errorf("want error for missing.log, got %v", err)
+	}
+	if err == nil || !strings.Contains(err.Error(), "*.missing: no matching files") {
+		t.Errorf("want error for *.missing, got %v", err)
+	}
+	want := "2 a.log\n2 total\n"
+	if got := output.String(); want != got {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
+
+func TestReportAbove(t *testing.T) {
+	t.Parallel()
+	want := "2 a.log\n3 sub/c.log\n6 total\n"
+	got := report(t, "-e", "error", "-above", "1", ".")
+	if want != got {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
+
+func TestReportFilesWithMatches(t *testing.T) {
+	t.Parallel()
+	want := "a.log\nnotes.txt\nsub/c.log\n"
+	got := report(t, "-e", "error", "-l", ".")
+	if want != got {
+		t.Errorf("want %q, got %q", want, got)
+	}
+	want = "sub/c.log\n"
+	got = report(t, "-e", "error", "-l", "-above", "2", ".")
+	if want != got {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
+
+func TestReportFilesWithoutMatch(t *testing.T) {
+	t.Parallel()
+	want := "b.log\nsub/deep/d.log\n"
+	got := report(t, "-e", "error", "-L", ".")
+	if want != got {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
+
+func TestReportPatternTable(t *testing.T) {
+	t.Parallel()
+	want := "" +
+		" a.log b.log total\n" +
+		"     2     0     2 error\n" +
+		"     1     1     2 ok\n"
+	got := report(t, "-e", "error", "-e", "ok", "a.log", "b.log")
+	if want != got {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
+
+func TestReportPatternTableAboveAll(t *testing.T) {
+	t.Parallel()
+	got := report(t, "-e", "error", "-e", "ok", "-above", "100", "a.log")
+	if got != "" {
+		t.Errorf("want no output, got %q", got)
+	}
+}
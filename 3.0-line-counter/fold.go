@@ -0,0 +1,111 @@
+package count
+
+import (
+	"regexp/syntax"
+	"unicode"
+	"unicode/utf8"
+)
+
+// fullFolds lists the runes whose full case folding, unlike their simple
+// folding, expands to several runes (status F in Unicode's CaseFolding.txt).
+var fullFolds = map[rune]string{
+	'\u00df': "ss", '\u1e9e': "ss",
+	'\u0130': "i\u0307",
+	'\u0149': "\u02bcn", '\u01f0': "j\u030c",
+	'\u1e96': "h\u0331", '\u1e97': "t\u0308", '\u1e98': "w\u030a", '\u1e99': "y\u030a", '\u1e9a': "a\u02be",
+	'\u0390': "\u03b9\u0308\u0301", '\u1fd3': "\u03b9\u0308\u0301",
+	'\u03b0': "\u03c5\u0308\u0301", '\u1fe3': "\u03c5\u0308\u0301",
+	'\u1fb3': "\u03b1\u03b9", '\u1fbc': "\u03b1\u03b9",
+	'\u1fc3': "\u03b7\u03b9", '\u1fcc': "\u03b7\u03b9",
+	'\u1ff3': "\u03c9\u03b9", '\u1ffc': "\u03c9\u03b9",
+	'\u1fb2': "\u1f70\u03b9", '\u1fb4': "\u03ac\u03b9",
+	'\u1fc2': "\u1f74\u03b9", '\u1fc4': "\u03ae\u03b9",
+	'\u1ff2': "\u1f7c\u03b9", '\u1ff4': "\u03ce\u03b9",
+	'\u1fb6': "\u03b1\u0342", '\u1fc6': "\u03b7\u0342", '\u1fd6': "\u03b9\u0342",
+	'\u1fe6': "\u03c5\u0342", '\u1ff6': "\u03c9\u0342", '\u1fe4': "\u03c1\u0313",
+	'\u0587': "\u0565\u0582", '\ufb13': "\u0574\u0576", '\ufb14': "\u0574\u0565",
+	'\ufb15': "\u0574\u056b", '\ufb16': "\u057e\u0576", '\ufb17': "\u0574\u056d",
+	'\ufb00': "ff", '\ufb01': "fi", '\ufb02': "fl", '\ufb03': "ffi", '\ufb04': "ffl",
+	'\ufb05': "st", '\ufb06': "st",
+}
+
+// foldCase appends the full Unicode case folding of src to dst, so that "ß"
+// matches "SS" and "ς" matches "Σ".
+func foldCase(dst, src []byte) []byte {
+	return appendFolded(dst, src, false)
+}
+
+// foldCaseTurkish folds like foldCase, but pairs dotted İ with i and dotless I
+// with ı, as Turkish and Azerbaijani do.
+func foldCaseTurkish(dst, src []byte) []byte {
+	return appendFolded(dst, src, true)
+}
+
+func appendFolded(dst, src []byte, turkish bool) []byte {
+	var encoded [utf8.UTFMax]byte
+	for i := 0; i < len(src); {
+		if b := src[i]; b < utf8.RuneSelf {
+			switch {
+			case b == 'I' && turkish:
+				dst = append(dst, "ı"...)
+			case 'A' <= b && b <= 'Z':
+				dst = append(dst, b+'a'-'A')
+			default:
+				dst = append(dst, b)
+			}
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRune(src[i:])
+		switch full, ok := fullFolds[r]; {
+		case r == utf8.RuneError && size == 1:
+			dst = append(dst, src[i])
+		case r == 'İ' && turkish:
+			dst = append(dst, 'i')
+		case ok:
+			dst = append(dst, full...)
+		default:
+			n := utf8.EncodeRune(encoded[:], simpleFold(r))
+			dst = append(dst, encoded[:n]...)
+		}
+		i += size
+	}
+	return dst
+}
+
+// simpleFold maps every rune of a case folding orbit, like k, K and the
+// Kelvin sign, to the same rune, normally the lower case one. Runes outside
+// any orbit, like the dotless ı, fold to themselves.
+func simpleFold(r rune) rune {
+	if unicode.SimpleFold(r) == r {
+		return r
+	}
+	return unicode.ToLower(unicode.ToUpper(r))
+}
+
+func foldString(fold func(dst, src []byte) []byte, s string) string {
+	if fold == nil {
+		return s
+	}
+	return string(fold(nil, []byte(s)))
+}
+
+// foldLiterals folds the literals of a regular expression, so that it matches
+// lines folded by fold.
+func foldLiterals(expr string, fold func(dst, src []byte) []byte) (string, error) {
+	re, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return "", err
+	}
+	var walk func(re *syntax.Regexp)
+	walk = func(re *syntax.Regexp) {
+		if re.Op == syntax.OpLiteral {
+			re.Rune = []rune(foldString(fold, string(re.Rune)))
+		}
+		for _, sub := range re.Sub {
+			walk(sub)
+		}
+	}
+	walk(re)
+	return re.String(), nil
+}
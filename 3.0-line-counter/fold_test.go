@@ -0,0 +1,125 @@
+package count_test
+
+import (
+	"count"
+	"strings"
+	"testing"
+)
+
+// countFolded counts the lines of input matching pattern, as a fixed string
+// or a regexp, ignoring case in locale.
+func countFolded(t *testing.T, pattern, input, locale string, regexp bool) int {
+	t.Helper()
+	with := count.WithPattern(pattern)
+	if regexp {
+		with = count.WithRegexp(pattern)
+	}
+	c, err := count.NewCounter(
+		count.WithInput(strings.NewReader(input)),
+		with,
+		count.IgnoreCaseIn(locale),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.Lines()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+func TestUnicodeIgnoreCase(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		pattern string
+		input   string
+		want    int
+	}{
+		{"straße", "STRASSE\nStrasse\nstraße\nSTRAẞE\nstrase\n", 4},
+		{"SS", "ß\nẞ\ns\n", 2},
+		{"ΟΔΟΣ", "οδος\nοδοσ\nοδος\nΟΔΟΣ\nοδοί\n", 4},
+		{"ς", "Σ\nσ\nς\nς\nα\n", 4},
+		{"москва", "МОСКВА\nМосква\nмосква\nминск\n", 3},
+		{"ǆ", "Ǆ\nǅ\nǆ\nd\n", 3},
+		{"kelvin", "KELVIN\nKelvin\nkelvin\n", 3},
+		{"ﬁle", "FILE\nfile\nﬁle\n", 3},
+		{"ᾳ", "ΑΙ\nαι\nᾼ\nα\n", 3},
+		{"Ꭰ", "ꭰ\nᎠ\n", 2},
+		{"istanbul", "İSTANBUL\nISTANBUL\nistanbul\n", 2},
+	}
+	for _, test := range tests {
+		for _, regexp := range []bool{false, true} {
+			got := countFolded(t, test.pattern, test.input, "", regexp)
+			if test.want != got {
+				t.Errorf("%q in %q, regexp %v: want %d, got %d", test.pattern, test.input, regexp, test.want, got)
+			}
+		}
+	}
+}
+
+func TestTurkishIgnoreCase(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		pattern string
+		input   string
+		want    int
+	}{
+		{"istanbul", "İSTANBUL\nISTANBUL\nistanbul\nİstanbul\n", 3},
+		{"DİYARBAKIR", "diyarbakır\nDiyarbakır\ndiyarbakir\nDIYARBAKIR\n", 2},
+		{"ırmak", "IRMAK\nırmak\nirmak\n", 2},
+		{"dış", "DIŞ\ndis\n", 1},
+	}
+	for _, test := range tests {
+		for _, regexp := range []bool{false, true} {
+			got := countFolded(t, test.pattern, test.input, "tr", regexp)
+			if test.want != got {
+				t.Errorf("%q in %q, regexp %v: want %d, got %d", test.pattern, test.input, regexp, test.want, got)
+			}
+		}
+	}
+}
+
+func TestUnsupportedLocale(t *testing.T) {
+	t.Parallel()
+	if _, err := count.NewCounter(count.IgnoreCaseIn("xx")); err == nil {
+		t.Error("want error for unsupported locale")
+	}
+}
+
+func TestIgnoreCaseInvalidUTF8(t *testing.T) {
+	t.Parallel()
+	c, err := count.NewCounter(
+		count.WithInput(strings.NewReader("\xffABC\xfe\nabc\n\xff\n")),
+		count.WithPattern("\xffabc"),
+		count.IgnoreCase(),
+	)
+	assertLines(t, c, err, 1)
+}
+
+func TestIgnoreCaseDoesNotAllocatePerLine(t *testing.T) {
+	input := func(lines int) string {
+		return strings.Repeat("Grüße aus KÖLN, ΟΔΟΣ\n", lines)
+	}
+	allocs := func(lines int) float64 {
+		text := input(lines)
+		return testing.AllocsPerRun(10, func() {
+			c, err := count.NewCounter(
+				count.WithInput(strings.NewReader(text)),
+				count.WithPattern("köln"),
+				count.WithPattern("grüsse"),
+				count.IgnoreCase(),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := c.Lines(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+	few, many := allocs(10), allocs(10000)
+	if many > few {
+		t.Errorf("want allocations independent of line count, got %v for 10 lines and %v for 10000", few, many)
+	}
+}
@@ -0,0 +1,229 @@
+package count
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+type followMode struct {
+	enabled      bool
+	interval     time.Duration
+	window       time.Duration
+	pollInterval time.Duration
+}
+
+// Follow keeps reading the file being counted as it grows, like tail -F, and
+// reports the running match count and rate every interval, until the context
+// given by WithContext is done.
+func Follow(interval time.Duration) option {
+	return func(c *counter) error {
+		if interval <= 0 {
+			return errors.New("follow needs a positive report interval")
+		}
+		c.follow.enabled = true
+		c.follow.interval = interval
+		return nil
+	}
+}
+
+// RateWindow sets the sliding window over which Follow computes the rate.
+func RateWindow(window time.Duration) option {
+	return func(c *counter) error {
+		if window <= 0 {
+			return errors.New("rate window must be positive")
+		}
+		c.follow.window = window
+		return nil
+	}
+}
+
+// PollInterval sets how often Follow checks the file for new data, truncation
+// and rotation.
+func PollInterval(interval time.Duration) option {
+	return func(c *counter) error {
+		if interval <= 0 {
+			return errors.New("poll interval must be positive")
+		}
+		c.follow.pollInterval = interval
+		return nil
+	}
+}
+
+func WithContext(ctx context.Context) option {
+	return func(c *counter) error {
+		c.ctx = ctx
+		return nil
+	}
+}
+
+type rateSample struct {
+	at      time.Time
+	matches int
+}
+
+// follower tails one file, reopening it when it is rotated and rewinding when
+// it is truncated.
+type follower struct {
+	c       counter
+	path    string
+	file    *os.File
+	info    os.FileInfo
+	offset  int64
+	partial []byte
+	buf     []byte
+	tally   tally
+	scratch *scratch
+	samples []rateSample
+}
+
+func (c counter) followFile() error {
+	if len(c.paths) != 1 || c.paths[0] == stdin {
+		return errors.New("follow needs exactly one file")
+	}
+	f := &follower{
+		c:       c,
+		path:    c.paths[0],
+		buf:     make([]byte, 64<<10),
+		tally:   c.newTally(),
+		scratch: c.newScratch(),
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	defer func() { f.file.Close() }()
+	offset, err := f.file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	f.offset = offset
+
+	start := time.Now()
+	f.samples = []rateSample{{at: start}}
+	poll := time.NewTicker(c.follow.pollInterval)
+	defer poll.Stop()
+	report := time.NewTicker(c.follow.interval)
+	defer report.Stop()
+	for {
+		select {
+		case <-c.ctx.Done():
+			if err := f.poll(); err != nil {
+				return err
+			}
+			_, err := fmt.Fprintf(c.output, "%d matches in %d lines over %s\n",
+				f.tally.Matches, f.tally.Lines, time.Since(start).Round(time.Millisecond))
+			return err
+		case now := <-poll.C:
+			if err := f.poll(); err != nil {
+				return err
+			}
+			f.sample(now)
+		case now := <-report.C:
+			_, err := fmt.Fprintf(c.output, "%d matches, %.2f/s\n", f.tally.Matches, f.rate(now))
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (f *follower) open() error {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file, f.info, f.offset, f.partial = file, info, 0, f.partial[:0]
+	return nil
+}
+
+// poll counts whatever was appended since the last poll, then checks whether
+// the file was truncated or replaced.
+func (f *follower) poll() error {
+	if err := f.drain(); err != nil {
+		return err
+	}
+	info, err := os.Stat(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		// rotated away, and not yet recreated
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !os.SameFile(info, f.info) {
+		f.file.Close()
+		if err := f.open(); err != nil {
+			return err
+		}
+		return f.drain()
+	}
+	if info.Size() < f.offset {
+		f.offset, f.partial = 0, f.partial[:0]
+		if _, err := f.file.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		return f.drain()
+	}
+	return nil
+}
+
+// drain reads to the current end of the file, counting complete lines and
+// keeping a trailing partial line for later.
+func (f *follower) drain() error {
+	for {
+		n, err := f.file.Read(f.buf)
+		f.offset += int64(n)
+		data := f.buf[:n]
+		for {
+			i := bytes.IndexByte(data, '\n')
+			if i < 0 {
+				break
+			}
+			line := append(f.partial, data[:i]...)
+			line = bytes.TrimSuffix(line, []byte("\r"))
+			f.c.add(&f.tally, line, f.scratch)
+			f.partial = line[:0]
+			data = data[i+1:]
+		}
+		f.partial = append(f.partial, data...)
+		if f.c.maxLineLength > 0 && len(f.partial) > f.c.maxLineLength {
+			return fmt.Errorf("line %d: %w", f.tally.Lines+1, ErrLineTooLong)
+		}
+		if err == io.EOF || n == 0 {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (f *follower) sample(now time.Time) {
+	f.samples = append(f.samples, rateSample{at: now, matches: f.tally.Matches})
+	// keep one sample at or before the start of the window
+	cutoff := now.Add(-f.c.follow.window)
+	drop := 0
+	for drop+1 < len(f.samples) && !f.samples[drop+1].at.After(cutoff) {
+		drop++
+	}
+	f.samples = f.samples[drop:]
+}
+
+// rate returns matches per second over the sliding window.
+func (f *follower) rate(now time.Time) float64 {
+	oldest := f.samples[0]
+	elapsed := now.Sub(oldest.at).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(f.tally.Matches-oldest.matches) / elapsed
+}
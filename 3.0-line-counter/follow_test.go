@@ -0,0 +1,138 @@
+package count_test
+
+import (
+	"bytes"
+	"context"
+	"count"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func appendFile(t *testing.T, path, data string) {
+	t.Helper()
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if _, err := file.WriteString(data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// waitForMatches waits until the last periodic report of the follower shows
+// want matches.
+func waitForMatches(t *testing.T, output *syncBuffer, want int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	got := -1
+	for time.Now().Before(deadline) {
+		lines := strings.Split(output.String(), "\n")
+		for i := len(lines) - 1; i >= 0; i-- {
+			if strings.Contains(lines[i], " matches, ") {
+				fmt.Sscanf(lines[i], "%d matches, ", &got)
+				break
+			}
+		}
+		if got == want {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("want a report of %d matches, last got %d", want, got)
+}
+
+func TestFollow(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, path, "error: before following\n")
+	output := &syncBuffer{}
+	ctx, cancel := context.WithCancel(context.Background())
+	c, err := count.NewCounter(
+		count.WithPaths(path),
+		count.WithPattern("error"),
+		count.WithOutput(output),
+		count.WithContext(ctx),
+		count.Follow(20*time.Millisecond),
+		count.PollInterval(5*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error)
+	go func() { done <- c.Report() }()
+	// the first report comes once the follower is past the existing lines
+	waitForMatches(t, output, 0)
+
+	appendFile(t, path, "error: one\nok\nerror: tw")
+	waitForMatches(t, output, 1)
+	appendFile(t, path, "o\n")
+	waitForMatches(t, output, 2)
+
+	// truncate in place, as copytruncate rotation does
+	if err := os.Truncate(path, 0); err != nil {
+		t.Fatal(err)
+	}
+	// shorter than what was read, so the truncation shows even if missed
+	appendFile(t, path, "error: three\n")
+	waitForMatches(t, output, 3)
+
+	// move away and recreate, as create rotation does
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, path+".1", "error: four, late write to the old file\n")
+	waitForMatches(t, output, 4)
+	appendFile(t, path, "error: five\nok\n")
+	waitForMatches(t, output, 5)
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	got := output.String()
+	if !strings.Contains(got, "matches, ") || !strings.Contains(got, "/s\n") {
+		t.Errorf("want periodic rate reports, got %q", got)
+	}
+	lines := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+	summary := lines[len(lines)-1]
+	if !strings.HasPrefix(summary, "5 matches in 7 lines over ") {
+		t.Errorf("want summary of 5 matches in 7 lines, got %q", summary)
+	}
+}
+
+func TestFollowNeedsOneFile(t *testing.T) {
+	t.Parallel()
+	c, err := count.NewCounter(
+		count.WithPaths("a", "b"),
+		count.Follow(time.Second),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Report(); err == nil {
+		t.Error("want error following two files")
+	}
+}
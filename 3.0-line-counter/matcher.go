@@ -0,0 +1,222 @@
+package count
+
+import (
+	"regexp"
+	"unicode"
+	"unicode/utf8"
+)
+
+// matcher sets s.hits[i] when pattern i occurs in line; hits come in cleared.
+type matcher interface {
+	match(line []byte, s *scratch)
+}
+
+// regexpMatcher matches regexps, against the case folded line if fold is set,
+// their literals being folded too.
+type regexpMatcher struct {
+	regexps []*regexp.Regexp
+	fold    func(dst, line []byte) []byte
+}
+
+func (m regexpMatcher) match(line []byte, s *scratch) {
+	line = m.foldLine(line, s)
+	for i, re := range m.regexps {
+		s.hits[i] = re.Match(line)
+	}
+}
+
+func (m regexpMatcher) foldLine(line []byte, s *scratch) []byte {
+	if m.fold == nil {
+		return line
+	}
+	s.folded = m.fold(s.folded[:0], line)
+	return s.folded
+}
+
+// mixedMatcher matches the fixed patterns with an automaton and the others
+// with regexps; the indexes map their patterns to the counter's.
+type mixedMatcher struct {
+	fixed       *ahoCorasick
+	fixedIndex  []int
+	regexps     regexpMatcher
+	regexpIndex []int
+}
+
+func (m mixedMatcher) match(line []byte, s *scratch) {
+	hits, fixed := s.hits, s.mixed[:len(m.fixedIndex)]
+	for j := range fixed {
+		fixed[j] = false
+	}
+	s.hits = fixed
+	m.fixed.match(line, s)
+	s.hits = hits
+	for j, i := range m.fixedIndex {
+		hits[i] = fixed[j]
+	}
+	line = m.regexps.foldLine(line, s)
+	for j, re := range m.regexps.regexps {
+		hits[m.regexpIndex[j]] = re.Match(line)
+	}
+}
+
+// ahoCorasick finds any number of fixed patterns in a single pass over the
+// line. Only the root has a transition for every byte; other states keep the
+// edges of the trie, sorted by byte, and fall back along failure links, so
+// that large pattern sets take memory in proportion to their size.
+type ahoCorasick struct {
+	root  [256]int32
+	edges [][]acEdge
+	fail  []int32
+	// dict links a state to the nearest state with outputs along its failure
+	// links, or to -1
+	dict      []int32
+	outputs   [][]int
+	lengths   []int
+	fold      func(dst, line []byte) []byte
+	wholeWord bool
+}
+
+type acEdge struct {
+	b    byte
+	next int32
+}
+
+func newAhoCorasick(patterns []string, fold func(dst, line []byte) []byte, wholeWord bool) *ahoCorasick {
+	ac := &ahoCorasick{
+		edges:     make([][]acEdge, 1),
+		outputs:   make([][]int, 1),
+		lengths:   make([]int, len(patterns)),
+		fold:      fold,
+		wholeWord: wholeWord,
+	}
+	// build the trie, using 0 (the root) as "no transition yet"
+	for i, pattern := range patterns {
+		pattern = foldString(fold, pattern)
+		ac.lengths[i] = len(pattern)
+		state := int32(0)
+		for j := 0; j < len(pattern); j++ {
+			next := ac.child(state, pattern[j])
+			if next == 0 {
+				next = int32(len(ac.edges))
+				ac.edges = append(ac.edges, nil)
+				ac.outputs = append(ac.outputs, nil)
+				ac.addChild(state, pattern[j], next)
+			}
+			state = next
+		}
+		ac.outputs[state] = append(ac.outputs[state], i)
+	}
+	// breadth first, so that failure links point to states already linked
+	ac.fail = make([]int32, len(ac.edges))
+	ac.dict = make([]int32, len(ac.edges))
+	ac.dict[0] = -1
+	queue := []int32{}
+	for b := 0; b < 256; b++ {
+		if next := ac.root[b]; next != 0 {
+			ac.dict[next] = ac.dictOf(0)
+			queue = append(queue, next)
+		}
+	}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		for _, edge := range ac.edges[state] {
+			fail := ac.next(ac.fail[state], edge.b)
+			ac.fail[edge.next] = fail
+			ac.dict[edge.next] = ac.dictOf(fail)
+			queue = append(queue, edge.next)
+		}
+	}
+	return ac
+}
+
+// child returns the state that state goes to on b in the trie, or 0.
+func (ac *ahoCorasick) child(state int32, b byte) int32 {
+	if state == 0 {
+		return ac.root[b]
+	}
+	edges := ac.edges[state]
+	lo, hi := 0, len(edges)
+	for lo < hi {
+		mid := (lo + hi) / 2
+		if edges[mid].b < b {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	if lo < len(edges) && edges[lo].b == b {
+		return edges[lo].next
+	}
+	return 0
+}
+
+func (ac *ahoCorasick) addChild(state int32, b byte, next int32) {
+	if state == 0 {
+		ac.root[b] = next
+		return
+	}
+	edges := ac.edges[state]
+	i := len(edges)
+	for i > 0 && edges[i-1].b > b {
+		i--
+	}
+	edges = append(edges, acEdge{})
+	copy(edges[i+1:], edges[i:])
+	edges[i] = acEdge{b, next}
+	ac.edges[state] = edges
+}
+
+// next follows the failure links of state until one has a transition on b.
+func (ac *ahoCorasick) next(state int32, b byte) int32 {
+	for state != 0 {
+		if next := ac.child(state, b); next != 0 {
+			return next
+		}
+		state = ac.fail[state]
+	}
+	return ac.root[b]
+}
+
+// dictOf returns state if it has outputs, or its own dictionary link.
+func (ac *ahoCorasick) dictOf(state int32) int32 {
+	if len(ac.outputs[state]) > 0 {
+		return state
+	}
+	return ac.dict[state]
+}
+
+func (ac *ahoCorasick) match(line []byte, s *scratch) {
+	if ac.fold != nil {
+		s.folded = ac.fold(s.folded[:0], line)
+		line = s.folded
+	}
+	hits := s.hits
+	for _, i := range ac.outputs[0] {
+		hits[i] = !ac.wholeWord || ac.isWord(line, 0, 0)
+	}
+	state := int32(0)
+	for end := 1; end <= len(line); end++ {
+		state = ac.next(state, line[end-1])
+		for found := ac.dictOf(state); found >= 0; found = ac.dict[found] {
+			for _, i := range ac.outputs[found] {
+				if !hits[i] && (!ac.wholeWord || ac.isWord(line, end-ac.lengths[i], end)) {
+					hits[i] = true
+				}
+			}
+		}
+	}
+}
+
+// isWord reports whether line[start:end] is a whole word: neither the rune
+// before it nor the one after it is a word constituent.
+func (ac *ahoCorasick) isWord(line []byte, start, end int) bool {
+	before, _ := utf8.DecodeLastRune(line[:start])
+	after, _ := utf8.DecodeRune(line[end:])
+	return (start == 0 || !isWordRune(before)) && (end == len(line) || !isWordRune(after))
+}
+
+// isWordRune reports whether r is a letter, a digit or an underscore.
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
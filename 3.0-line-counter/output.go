@@ -0,0 +1,178 @@
+package count
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type format int
+
+const (
+	textFormat format = iota
+	jsonFormat
+	csvFormat
+)
+
+// WithFormat selects how Report prints results: "text", "json" or "csv".
+func WithFormat(name string) option {
+	return func(c *counter) error {
+		switch name {
+		case "text":
+			c.format = textFormat
+		case "json":
+			c.format = jsonFormat
+		case "csv":
+			c.format = csvFormat
+		default:
+			return fmt.Errorf("unknown format %q", name)
+		}
+		return nil
+	}
+}
+
+type reportOptions struct {
+	Regexp     bool   `json:"regexp"`
+	IgnoreCase bool   `json:"ignore_case"`
+	Locale     string `json:"locale,omitempty"`
+	WholeWord  bool   `json:"whole_word"`
+	Invert     bool   `json:"invert"`
+}
+
+type fileReport struct {
+	Path           string `json:"path"`
+	Lines          int    `json:"lines"`
+	Matches        int    `json:"matches"`
+	PatternMatches []int  `json:"pattern_matches"`
+}
+
+type jsonReport struct {
+	Patterns       []string      `json:"patterns"`
+	Options        reportOptions `json:"options"`
+	Files          []fileReport  `json:"files"`
+	Lines          int           `json:"lines"`
+	Matches        int           `json:"matches"`
+	ElapsedSeconds float64       `json:"elapsed_seconds"`
+}
+
+type jsonLineCount struct {
+	Line  string `json:"line"`
+	Count int    `json:"count"`
+}
+
+func (c counter) reportOptions() reportOptions {
+	return reportOptions{
+		Regexp:     c.anyRegexp(),
+		IgnoreCase: c.ignoreCase,
+		Locale:     c.locale,
+		WholeWord:  c.wholeWord,
+		Invert:     c.invert,
+	}
+}
+
+// flags spells out the options in effect the way the command line takes them.
+func (c counter) flags() string {
+	var flags []string
+	options := c.reportOptions()
+	if options.Regexp {
+		flags = append(flags, "-E")
+	}
+	if options.IgnoreCase {
+		flags = append(flags, "-i")
+	}
+	if options.Locale != "" {
+		flags = append(flags, "-locale="+options.Locale)
+	}
+	if options.WholeWord {
+		flags = append(flags, "-w")
+	}
+	if options.Invert {
+		flags = append(flags, "-v")
+	}
+	return strings.Join(flags, " ")
+}
+
+func (c counter) printJSON(counts []FileCount, elapsed time.Duration) error {
+	total := c.total(counts)
+	report := jsonReport{
+		Patterns:       c.patterns,
+		Options:        c.reportOptions(),
+		Files:          make([]fileReport, len(counts)),
+		Lines:          total.Lines,
+		Matches:        total.Count,
+		ElapsedSeconds: elapsed.Seconds(),
+	}
+	for i, fc := range counts {
+		report.Files[i] = fileReport{
+			Path:           fc.Path,
+			Lines:          fc.Lines,
+			Matches:        fc.Count,
+			PatternMatches: fc.Patterns,
+		}
+	}
+	encoder := json.NewEncoder(c.output)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+// printCSV prints a row per file and a total row, with a column per pattern;
+// only the total row carries the elapsed time.
+func (c counter) printCSV(counts []FileCount, elapsed time.Duration) error {
+	w := csv.NewWriter(c.output)
+	header := append([]string{"path", "lines", "matches"}, c.patterns...)
+	header = append(header, "options", "elapsed_seconds")
+	if err := w.Write(header); err != nil {
+		return err
+	}
+	options := c.flags()
+	row := func(fc FileCount, seconds string) []string {
+		row := []string{fc.Path, strconv.Itoa(fc.Lines), strconv.Itoa(fc.Count)}
+		for _, n := range fc.Patterns {
+			row = append(row, strconv.Itoa(n))
+		}
+		return append(row, options, seconds)
+	}
+	for _, fc := range counts {
+		if err := w.Write(row(fc, "")); err != nil {
+			return err
+		}
+	}
+	seconds := strconv.FormatFloat(elapsed.Seconds(), 'f', -1, 64)
+	if err := w.Write(row(c.total(counts), seconds)); err != nil {
+		return err
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func (c counter) printLineCounts(counts []LineCount) error {
+	switch c.format {
+	case jsonFormat:
+		lines := make([]jsonLineCount, len(counts))
+		for i, lc := range counts {
+			lines[i] = jsonLineCount(lc)
+		}
+		return json.NewEncoder(c.output).Encode(lines)
+	case csvFormat:
+		w := csv.NewWriter(c.output)
+		if err := w.Write([]string{"count", "line"}); err != nil {
+			return err
+		}
+		for _, lc := range counts {
+			if err := w.Write([]string{strconv.Itoa(lc.Count), lc.Line}); err != nil {
+				return err
+			}
+		}
+		w.Flush()
+		return w.Error()
+	}
+	for _, lc := range counts {
+		if _, err := fmt.Fprintf(c.output, "%7d %s\n", lc.Count, lc.Line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
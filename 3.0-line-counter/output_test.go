@@ -0,0 +1,136 @@
+package count_test
+
+import (
+	"bytes"
+	"count"
+	"encoding/csv"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestReportJSON(t *testing.T) {
+	t.Parallel()
+	got := report(t, "-format", "json", "-i", "-e", "error", "-e", "ok", "a.log", "b.log")
+	var parsed map[string]interface{}
+	if err := json.Unmarshal([]byte(got), &parsed); err != nil {
+		t.Fatalf("%v in %s", err, got)
+	}
+	elapsed, ok := parsed["elapsed_seconds"].(float64)
+	if !ok || elapsed < 0 {
+		t.Errorf("want elapsed seconds, got %v", parsed["elapsed_seconds"])
+	}
+	delete(parsed, "elapsed_seconds")
+	var want map[string]interface{}
+	err := json.Unmarshal([]byte(`{
+		"patterns": ["error", "ok"],
+		"options": {"regexp": false, "ignore_case": true, "whole_word": false, "invert": false},
+		"files": [
+			{"path": "a.log", "lines": 3, "matches": 3, "pattern_matches": [2, 1]},
+			{"path": "b.log", "lines": 1, "matches": 1, "pattern_matches": [0, 1]}
+		],
+		"lines": 4,
+		"matches": 4
+	}`), &want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(want, parsed) {
+		t.Errorf("want %v, got %v", want, parsed)
+	}
+}
+
+func TestReportCSV(t *testing.T) {
+	t.Parallel()
+	got := report(t, "-format", "csv", "-w", "-v", "-e", "error", "a.log", "b.log")
+	records, err := csv.NewReader(strings.NewReader(got)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"path", "lines", "matches", "error", "options", "elapsed_seconds"},
+		{"a.log", "3", "1", "1", "-w -v", ""},
+		{"b.log", "1", "1", "1", "-w -v", ""},
+		{"total", "4", "2", "2", "-w -v"},
+	}
+	last := len(records) - 1
+	if len(records) != len(want) || records[last][5] == "" {
+		t.Fatalf("want %d records with elapsed time in the last, got %v", len(want), records)
+	}
+	records[last] = records[last][:5]
+	if !reflect.DeepEqual(want, records) {
+		t.Errorf("want %v, got %v", want, records)
+	}
+}
+
+func TestReportCSVFileNamedTotal(t *testing.T) {
+	t.Parallel()
+	output := &bytes.Buffer{}
+	c, err := count.NewCounter(
+		count.WithFS(fstest.MapFS{"total": {Data: []byte("error\n")}}),
+		count.WithOutput(output),
+		count.WithArgs([]string{"-format", "csv", "-e", "error", "total"}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Report(); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(output).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || records[1][0] != "total" || records[1][5] != "" || records[2][5] == "" {
+		t.Errorf("want elapsed time on the total row only, got %v", records)
+	}
+}
+
+func TestReportStdinJSON(t *testing.T) {
+	t.Parallel()
+	output := &bytes.Buffer{}
+	c, err := count.NewCounter(
+		count.WithInput(strings.NewReader("a\nb\na\n")),
+		count.WithOutput(output),
+		count.WithPattern("a"),
+		count.WithFormat("json"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Report(); err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Files []struct {
+			Path    string
+			Lines   int
+			Matches int
+		}
+		Matches int
+	}
+	if err := json.Unmarshal(output.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Files) != 1 || got.Files[0].Path != "-" || got.Files[0].Lines != 3 || got.Matches != 2 {
+		t.Errorf("want 2 matches in 3 lines of stdin, got %+v", got)
+	}
+}
+
+func TestReportFrequenciesCSV(t *testing.T) {
+	t.Parallel()
+	want := "count,line\n2,error\n2,ok\n"
+	got := report(t, "-u", "-format", "csv", "a.log", "b.log")
+	if want != got {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
+
+func TestUnknownFormat(t *testing.T) {
+	t.Parallel()
+	if _, err := count.NewCounter(count.WithFormat("xml")); err == nil {
+		t.Error("want error for unknown format")
+	}
+}
@@ -0,0 +1,110 @@
+package count
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+const defaultChunkSize = 4 << 20
+
+// WithWorkers sets how many goroutines count a regular file; 1 counts it
+// sequentially, like a stream.
+func WithWorkers(workers int) option {
+	return func(c *counter) error {
+		if workers < 1 {
+			return errors.New("need at least one worker")
+		}
+		c.workers = workers
+		return nil
+	}
+}
+
+// WithChunkSize sets the smallest piece of a file worth a worker of its own.
+func WithChunkSize(size int64) option {
+	return func(c *counter) error {
+		if size < 1 {
+			return errors.New("chunk size must be positive")
+		}
+		c.chunkSize = size
+		return nil
+	}
+}
+
+// countChunks splits a file into newline aligned chunks, counts them
+// concurrently and merges the results.
+func (c counter) countChunks(file io.ReaderAt, size int64) (tally, error) {
+	bounds, err := c.chunkBounds(file, size)
+	if err != nil {
+		return tally{}, err
+	}
+	chunks := len(bounds) - 1
+	tallies := make([]tally, chunks)
+	errs := make([]error, chunks)
+	var wg sync.WaitGroup
+	for i := 0; i < chunks; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			section := io.NewSectionReader(file, bounds[i], bounds[i+1]-bounds[i])
+			tallies[i], errs[i] = c.scan(section)
+		}(i)
+	}
+	wg.Wait()
+	t := c.newTally()
+	for i := range tallies {
+		if errs[i] == ErrLineTooLong {
+			return t, fmt.Errorf("line %d: %w", t.Lines+tallies[i].Lines+1, errs[i])
+		}
+		if errs[i] != nil {
+			return t, errs[i]
+		}
+		t.merge(tallies[i])
+	}
+	return t, nil
+}
+
+// chunkBounds returns the offsets where chunks start, followed by size. Each
+// chunk but the first starts right after a newline.
+func (c counter) chunkBounds(file io.ReaderAt, size int64) ([]int64, error) {
+	chunks := int64(c.workers)
+	if limit := size / c.chunkSize; limit < chunks {
+		chunks = limit
+	}
+	bounds := []int64{0}
+	for i := int64(1); i < chunks; i++ {
+		from := size * i / chunks
+		if last := bounds[len(bounds)-1]; from <= last {
+			continue
+		}
+		start, err := nextLineStart(file, from, size)
+		if err != nil {
+			return nil, err
+		}
+		if start > bounds[len(bounds)-1] && start < size {
+			bounds = append(bounds, start)
+		}
+	}
+	return append(bounds, size), nil
+}
+
+// nextLineStart returns the offset following the first newline at or after
+// from-1, or size if there is none.
+func nextLineStart(file io.ReaderAt, from, size int64) (int64, error) {
+	buf := make([]byte, 64<<10)
+	for offset := from - 1; offset < size; offset += int64(len(buf)) {
+		n, err := file.ReadAt(buf, offset)
+		if i := bytes.IndexByte(buf[:n], '\n'); i >= 0 {
+			return offset + int64(i) + 1, nil
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	return size, nil
+}
@@ -0,0 +1,142 @@
+package count_test
+
+import (
+	"bufio"
+	"count"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestChunkBoundaries(t *testing.T) {
+	t.Parallel()
+	inputs := map[string]string{
+		"short lines":        strings.Repeat("ab\nerror\n\nx error y\n", 50),
+		"no final newline":   strings.Repeat("error\nok\n", 30) + "error",
+		"long lines":         strings.Repeat(strings.Repeat("x", 100)+"error\n", 10),
+		"only newlines":      strings.Repeat("\n", 100),
+		"crlf":               strings.Repeat("error\r\nok\r\n", 40),
+		"one long line":      strings.Repeat("error ", 100),
+		"newline at chunk 1": "0123456\nerror\n" + strings.Repeat("error\n", 20),
+	}
+	for name, input := range inputs {
+		fsys := fstest.MapFS{"input": {Data: []byte(input)}}
+		var want []count.FileCount
+		for _, workers := range []int{1, 2, 3, 4, 7} {
+			for _, chunkSize := range []int64{1, 7, 8, 64} {
+				c, err := count.NewCounter(
+					count.WithFS(fsys),
+					count.WithPaths("input"),
+					count.WithPattern("error"),
+					count.WithPattern("ok"),
+					count.WithWorkers(workers),
+					count.WithChunkSize(chunkSize),
+				)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := c.Files()
+				if err != nil {
+					t.Fatalf("%s, %d workers, chunk size %d: %v", name, workers, chunkSize, err)
+				}
+				if want == nil {
+					want = got
+				} else if !reflect.DeepEqual(want, got) {
+					t.Errorf("%s, %d workers, chunk size %d: want %v, got %v", name, workers, chunkSize, want, got)
+				}
+			}
+		}
+	}
+}
+
+func TestChunkedLineTooLong(t *testing.T) {
+	t.Parallel()
+	input := strings.Repeat("short\n", 100) + strings.Repeat("x", 200) + "\nshort\n"
+	c, err := count.NewCounter(
+		count.WithFS(fstest.MapFS{"input": {Data: []byte(input)}}),
+		count.WithPaths("input"),
+		count.WithMaxLineLength(100),
+		count.WithWorkers(8),
+		count.WithChunkSize(16),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.Files()
+	if !errors.Is(err, count.ErrLineTooLong) {
+		t.Fatalf("want ErrLineTooLong, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "line 101:") {
+		t.Errorf("want error on line 101, got %v", err)
+	}
+}
+
+func benchmarkFile(b *testing.B) string {
+	b.Helper()
+	path := filepath.Join(b.TempDir(), "bench.log")
+	var sb strings.Builder
+	for i := 0; sb.Len() < 64<<20; i++ {
+		fmt.Fprintf(&sb, "2021-11-05T14:30:%02d level=info msg=\"request %d served\" code=E%d\n", i%60, i, i%13)
+	}
+	if err := os.WriteFile(path, []byte(sb.String()), 0o644); err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(sb.Len()))
+	return path
+}
+
+func benchmarkCount(b *testing.B, workers int) {
+	path := benchmarkFile(b)
+	c, err := count.NewCounter(
+		count.WithPaths(path),
+		count.WithPattern("code=E7"),
+		count.WithWorkers(workers),
+	)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := c.Files(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCountSequential(b *testing.B) {
+	benchmarkCount(b, 1)
+}
+
+func BenchmarkCountParallel(b *testing.B) {
+	benchmarkCount(b, runtime.GOMAXPROCS(0))
+}
+
+// BenchmarkCountScanner is the baseline: the single bufio.Scanner loop that
+// counted lines before they were read in chunks and split among workers.
+func BenchmarkCountScanner(b *testing.B) {
+	path := benchmarkFile(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		file, err := os.Open(path)
+		if err != nil {
+			b.Fatal(err)
+		}
+		lines := 0
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			if strings.Contains(scanner.Text(), "code=E7") {
+				lines++
+			}
+		}
+		file.Close()
+		if err := scanner.Err(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,314 @@
+package count
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"hash/maphash"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+type LineCount struct {
+	Line  string
+	Count int
+}
+
+type uniqueMode struct {
+	enabled     bool
+	top         int
+	minCount    int
+	lexically   bool
+	normalizers []func(string) string
+	// sketchWidth > 0 selects approximate counting in a count-min sketch
+	sketchWidth int
+	sketchDepth int
+}
+
+var (
+	spaceRe = regexp.MustCompile(`\s+`)
+	digitRe = regexp.MustCompile(`[0-9]+`)
+	uuidRe  = regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`)
+)
+
+// Unique switches from counting matching lines to counting how often each
+// distinct matching line occurs, like sort | uniq -c.
+func Unique() option {
+	return func(c *counter) error {
+		c.unique.enabled = true
+		return nil
+	}
+}
+
+// Top keeps only the n most frequent lines.
+func Top(n int) option {
+	return func(c *counter) error {
+		if n < 1 {
+			return errors.New("top needs a positive count")
+		}
+		c.unique.top = n
+		return nil
+	}
+}
+
+func MinCount(n int) option {
+	return func(c *counter) error {
+		c.unique.minCount = n
+		return nil
+	}
+}
+
+// SortLexically orders unique lines by content instead of by frequency.
+func SortLexically() option {
+	return func(c *counter) error {
+		c.unique.lexically = true
+		return nil
+	}
+}
+
+// Normalize rewrites lines, after case folding, before they are grouped.
+func Normalize(normalizer func(string) string) option {
+	return func(c *counter) error {
+		c.unique.normalizers = append(c.unique.normalizers, normalizer)
+		return nil
+	}
+}
+
+func CollapseSpace() option {
+	return Normalize(func(line string) string {
+		return strings.TrimSpace(spaceRe.ReplaceAllString(line, " "))
+	})
+}
+
+func WithMask(expr, replacement string) option {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return func(*counter) error { return err }
+	}
+	return Normalize(func(line string) string {
+		return re.ReplaceAllLiteralString(line, replacement)
+	})
+}
+
+func MaskDigits() option {
+	return Normalize(func(line string) string {
+		return digitRe.ReplaceAllLiteralString(line, "#")
+	})
+}
+
+func MaskUUIDs() option {
+	return Normalize(func(line string) string {
+		return uuidRe.ReplaceAllLiteralString(line, "<uuid>")
+	})
+}
+
+// Approximate counts unique lines in a count-min sketch of the given
+// dimensions, so memory stays bounded however many distinct lines there are.
+// It requires Top.
+func Approximate(width, depth int) option {
+	return func(c *counter) error {
+		if width < 1 || depth < 1 {
+			return errors.New("sketch dimensions must be positive")
+		}
+		c.unique.sketchWidth = width
+		c.unique.sketchDepth = depth
+		return nil
+	}
+}
+
+func (c counter) normalize(line string) string {
+	line = foldString(c.fold, line)
+	for _, normalizer := range c.unique.normalizers {
+		line = normalizer(line)
+	}
+	return line
+}
+
+type frequencyTable interface {
+	add(line string)
+	counts() []LineCount
+}
+
+type exactTable map[string]int
+
+func (table exactTable) add(line string) {
+	table[line]++
+}
+
+func (table exactTable) counts() []LineCount {
+	counts := make([]LineCount, 0, len(table))
+	for line, count := range table {
+		counts = append(counts, LineCount{line, count})
+	}
+	return counts
+}
+
+// sketchTable estimates counts in a count-min sketch and tracks the lines with
+// the highest estimates in a min-heap.
+type sketchTable struct {
+	rows  [][]uint32
+	seeds []maphash.Seed
+	top   int
+	heap  lineHeap
+}
+
+func newSketchTable(width, depth, top int) *sketchTable {
+	table := &sketchTable{
+		rows:  make([][]uint32, depth),
+		seeds: make([]maphash.Seed, depth),
+		top:   top,
+		heap:  lineHeap{index: make(map[string]int, top)},
+	}
+	for i := range table.rows {
+		table.rows[i] = make([]uint32, width)
+		table.seeds[i] = maphash.MakeSeed()
+	}
+	return table
+}
+
+func (table *sketchTable) add(line string) {
+	var hash maphash.Hash
+	estimate := uint32(0)
+	for i, row := range table.rows {
+		hash.SetSeed(table.seeds[i])
+		hash.WriteString(line)
+		cell := &row[hash.Sum64()%uint64(len(row))]
+		*cell++
+		if i == 0 || *cell < estimate {
+			estimate = *cell
+		}
+	}
+	count := int(estimate)
+	if i, ok := table.heap.index[line]; ok {
+		table.heap.lines[i].Count = count
+		heap.Fix(&table.heap, i)
+	} else if len(table.heap.lines) < table.top {
+		heap.Push(&table.heap, LineCount{line, count})
+	} else if count > table.heap.lines[0].Count {
+		delete(table.heap.index, table.heap.lines[0].Line)
+		table.heap.lines[0] = LineCount{line, count}
+		table.heap.index[line] = 0
+		heap.Fix(&table.heap, 0)
+	}
+}
+
+func (table *sketchTable) counts() []LineCount {
+	return append([]LineCount(nil), table.heap.lines...)
+}
+
+// lineHeap is a min-heap by count that keeps index up to date with the
+// position of each line.
+type lineHeap struct {
+	lines []LineCount
+	index map[string]int
+}
+
+func (h lineHeap) Len() int           { return len(h.lines) }
+func (h lineHeap) Less(i, j int) bool { return h.lines[i].Count < h.lines[j].Count }
+
+func (h lineHeap) Swap(i, j int) {
+	h.lines[i], h.lines[j] = h.lines[j], h.lines[i]
+	h.index[h.lines[i].Line] = i
+	h.index[h.lines[j].Line] = j
+}
+
+func (h *lineHeap) Push(x interface{}) {
+	line := x.(LineCount)
+	h.index[line.Line] = len(h.lines)
+	h.lines = append(h.lines, line)
+}
+
+func (h *lineHeap) Pop() interface{} {
+	last := h.lines[len(h.lines)-1]
+	h.lines = h.lines[:len(h.lines)-1]
+	delete(h.index, last.Line)
+	return last
+}
+
+func (c counter) newFrequencyTable() frequencyTable {
+	if c.unique.sketchWidth > 0 {
+		return newSketchTable(c.unique.sketchWidth, c.unique.sketchDepth, c.unique.top)
+	}
+	return exactTable{}
+}
+
+// Frequencies returns how often each distinct normalized line that matches
+// occurs, in the input or across all paths.
+func (c counter) Frequencies() ([]LineCount, error) {
+	table := c.newFrequencyTable()
+	s := c.newScratch()
+	add := func(line []byte) {
+		if c.matches(line, s) {
+			table.add(c.normalize(string(line)))
+		}
+	}
+	if len(c.paths) == 0 {
+		if err := c.eachLineOf(stdin, add); err != nil {
+			return nil, err
+		}
+	} else {
+		paths, errs := c.expand()
+		if len(errs) > 0 {
+			return nil, errs
+		}
+		for _, path := range paths {
+			if err := c.eachLineOf(path, add); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return c.rank(table.counts()), nil
+}
+
+func (c counter) eachLineOf(path string, f func(line []byte)) error {
+	input := c.input
+	if path != stdin {
+		file, err := c.fsys.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		input = file
+	}
+	input, err := c.open(input)
+	if err == nil {
+		err = c.eachLine(input, f)
+	}
+	if err != nil && path != stdin {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return err
+}
+
+// rank applies the minimum count and top-n limits, then orders the lines.
+func (c counter) rank(counts []LineCount) []LineCount {
+	kept := counts[:0]
+	for _, lc := range counts {
+		if lc.Count >= c.unique.minCount {
+			kept = append(kept, lc)
+		}
+	}
+	byFrequency := func(i, j int) bool {
+		if kept[i].Count != kept[j].Count {
+			return kept[i].Count > kept[j].Count
+		}
+		return kept[i].Line < kept[j].Line
+	}
+	sort.Slice(kept, byFrequency)
+	if c.unique.top > 0 && len(kept) > c.unique.top {
+		kept = kept[:c.unique.top]
+	}
+	if c.unique.lexically {
+		sort.Slice(kept, func(i, j int) bool { return kept[i].Line < kept[j].Line })
+	}
+	return kept
+}
+
+func (c counter) printFrequencies() error {
+	counts, err := c.Frequencies()
+	if err != nil {
+		return err
+	}
+	return c.printLineCounts(counts)
+}
@@ -0,0 +1,158 @@
+package count_test
+
+import (
+	"bytes"
+	"count"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const accessLog = `GET /users/12 200
+GET /users/7 200
+get  /users/7   200
+POST /users 201
+GET /users/12 500
+GET /users/3f2504e0-4f89-11d3-9a0c-0305e82c3301 200
+GET /users/7 200
+`
+
+func TestFrequencies(t *testing.T) {
+	t.Parallel()
+	c, err := count.NewCounter(
+		count.WithInput(strings.NewReader(accessLog)),
+		count.Unique(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.Frequencies()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []count.LineCount{
+		{"GET /users/7 200", 2},
+		{"GET /users/12 200", 1},
+		{"GET /users/12 500", 1},
+		{"GET /users/3f2504e0-4f89-11d3-9a0c-0305e82c3301 200", 1},
+		{"POST /users 201", 1},
+		{"get  /users/7   200", 1},
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
+
+func TestFrequenciesNormalized(t *testing.T) {
+	t.Parallel()
+	c, err := count.NewCounter(
+		count.WithInput(strings.NewReader(accessLog)),
+		count.WithPattern("get"),
+		count.IgnoreCase(),
+		count.Unique(),
+		count.CollapseSpace(),
+		count.MaskUUIDs(),
+		count.MaskDigits(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.Frequencies()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []count.LineCount{
+		{"get /users/# #", 5},
+		{"get /users/<uuid> #", 1},
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
+
+func TestFrequenciesTopMinAndLexical(t *testing.T) {
+	t.Parallel()
+	input := "c\nb\nb\na\na\na\nd\nd\nd\nd\n"
+	c, err := count.NewCounter(
+		count.WithInput(strings.NewReader(input)),
+		count.Unique(),
+		count.Top(3),
+		count.MinCount(2),
+		count.SortLexically(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.Frequencies()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []count.LineCount{{"a", 3}, {"b", 2}, {"d", 4}}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
+
+func TestApproximateFrequencies(t *testing.T) {
+	t.Parallel()
+	var input strings.Builder
+	for i := 0; i < 10000; i++ {
+		fmt.Fprintf(&input, "noise %d\n", i)
+		if i%10 == 0 {
+			input.WriteString("frequent\n")
+		}
+		if i%20 == 0 {
+			input.WriteString("common\n")
+		}
+	}
+	c, err := count.NewCounter(
+		count.WithInput(strings.NewReader(input.String())),
+		count.Unique(),
+		count.Top(2),
+		count.Approximate(1<<12, 4),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.Frequencies()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Line != "frequent" || got[1].Line != "common" {
+		t.Fatalf("want frequent and common lines, got %v", got)
+	}
+	// a count-min sketch never underestimates
+	if got[0].Count < 1000 || got[1].Count < 500 {
+		t.Errorf("want counts of at least 1000 and 500, got %v", got)
+	}
+}
+
+func TestApproximateNeedsTop(t *testing.T) {
+	t.Parallel()
+	_, err := count.NewCounter(count.Unique(), count.Approximate(16, 2))
+	if err == nil {
+		t.Error("want error for approximate counts without top")
+	}
+}
+
+func TestReportFrequencies(t *testing.T) {
+	t.Parallel()
+	output := &bytes.Buffer{}
+	c, err := count.NewCounter(
+		count.WithFS(testFS),
+		count.WithOutput(output),
+		count.WithArgs([]string{"-u", "a.log", "b.log"}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Report(); err != nil {
+		t.Fatal(err)
+	}
+	want := "      2 error\n      2 ok\n"
+	got := output.String()
+	if want != got {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
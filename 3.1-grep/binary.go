@@ -0,0 +1,55 @@
+package grep
+
+import (
+	"bytes"
+	"unicode/utf8"
+)
+
+// BinaryMode is what to do with binary files, as with --binary-files.
+type BinaryMode int
+
+const (
+	// BinaryMatches prints "Binary file X matches" instead of the selected
+	// lines of a binary file
+	BinaryMatches BinaryMode = iota
+	// BinaryText searches binary files as if they were text (-a)
+	BinaryText
+	// BinaryWithoutMatch takes binary files to have no selected lines (-I)
+	BinaryWithoutMatch
+)
+
+// binaryProbe is how much of an input is checked to tell binary files from
+// text.
+const binaryProbe = 8 << 10
+
+// WithBinaryFiles sets what to do with inputs that look binary.
+func WithBinaryFiles(mode BinaryMode) option {
+	return func(searcher *Searcher) {
+		searcher.binaryFiles = mode
+	}
+}
+
+// binaryOffset returns the offset of the first NUL byte at the start of an
+// input, or else of the first byte that isn't valid UTF-8, or -1 if there is
+// neither. A rune cut at the end of head may continue past it.
+func binaryOffset(head []byte) int {
+	if i := bytes.IndexByte(head, 0); i >= 0 {
+		return i
+	}
+	for i := len(head) - 1; i >= 0 && i >= len(head)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(head[i]) {
+			if !utf8.FullRune(head[i:]) {
+				head = head[:i]
+			}
+			break
+		}
+	}
+	for i := 0; i < len(head); {
+		r, size := utf8.DecodeRune(head[i:])
+		if r == utf8.RuneError && size == 1 {
+			return i
+		}
+		i += size
+	}
+	return -1
+}
@@ -0,0 +1,421 @@
+package grep
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Exit statuses, as in GNU grep.
+const (
+	ExitMatch   = 0
+	ExitNoMatch = 1
+	ExitError   = 2
+)
+
+const stdin = "-"
+
+// RunCLI runs grep with the given command-line arguments and returns its exit
+// status.
+func RunCLI(args []string, input io.Reader, output, errOutput io.Writer) int {
+	flagSet := flag.NewFlagSet("grep", flag.ContinueOnError)
+	flagSet.SetOutput(errOutput)
+	flagSet.Usage = func() {
+		fmt.Fprintln(errOutput, "usage: grep [OPTION]... PATTERN [FILE]...")
+		fmt.Fprintln(errOutput, "   or: grep [OPTION]... --query QUERY | -f FILE [FILE]...")
+		flagSet.PrintDefaults()
+	}
+	extended := flagSet.Bool("E", false, "interpret PATTERN as an RE2 regular expression")
+	fixed := flagSet.Bool("F", false, "interpret PATTERN as a fixed string (the default)")
+	query := flagSet.String("query", "", "select lines matching `QUERY`, patterns combined with AND, OR, NOT and parentheses")
+	queryFile := flagSet.String("f", "", "read a query from `FILE`, each line an alternative")
+	ignoreCase := flagSet.Bool("i", false, "ignore case distinctions")
+	word := flagSet.Bool("w", false, "match only whole words")
+	line := flagSet.Bool("x", false, "match only whole lines")
+	quietFlag := flagSet.Bool("q", false, "print nothing, exit zero on the first match")
+	silent := flagSet.Bool("s", false, "suppress messages about nonexistent or unreadable files")
+	count := flagSet.Bool("c", false, "print only a count of selected lines per file")
+	withMatches := flagSet.Bool("l", false, "print only names of files with selected lines")
+	withoutMatch := flagSet.Bool("L", false, "print only names of files without selected lines")
+	maxCount := flagSet.Int("m", -1, "stop reading a file after `NUM` selected lines")
+	after := flagSet.Int("A", -1, "print `NUM` lines of context after selected lines")
+	before := flagSet.Int("B", -1, "print `NUM` lines of context before selected lines")
+	around := flagSet.Int("C", -1, "print `NUM` lines of context around selected lines")
+	recursive := flagSet.Bool("r", false, "search directories recursively")
+	hidden := flagSet.Bool("hidden", false, "search hidden files and directories with -r")
+	binaryFiles := binaryFlag(BinaryMatches)
+	flagSet.Var(&binaryFiles, "binary-files", "handle binary files as `TYPE`: binary, text or without-match")
+	flagSet.Var(binaryModeFlag{&binaryFiles, BinaryText}, "a", "process binary files as text, like --binary-files=text")
+	flagSet.Var(binaryModeFlag{&binaryFiles, BinaryWithoutMatch}, "I", "skip binary files, like --binary-files=without-match")
+	lineNumbers := flagSet.Bool("n", false, "print line numbers")
+	byteOffsets := flagSet.Bool("b", false, "print byte offsets")
+	onlyMatching := flagSet.Bool("o", false, "print only the matching parts of lines")
+	var showFilenames filenames
+	flagSet.Var(filenameFlag{&showFilenames, true}, "H", "print file names")
+	flagSet.Var(filenameFlag{&showFilenames, false}, "h", "don't print file names")
+	color := colorFlag(ColorNever)
+	flagSet.Var(&color, "color", "color output `WHEN`: never, always or auto")
+	replacement := flagSet.String("replace", "", "print selected lines with matches replaced by `TEXT`, in which $1 or ${name} is a capture group")
+	diff := flagSet.Bool("diff", false, "with --replace, print a unified diff of the replacements")
+	inPlace := flagSet.Bool("in-place", false, "with --replace, rewrite the files with their replacements")
+	jsonLines := flagSet.Bool("json", false, "print results as JSON Lines, in the format of rg --json")
+	workers := flagSet.Int("j", 0, "search `NUM` files at once, by default as many as there are CPUs")
+	unordered := flagSet.Bool("unordered", false, "print the output for each file as soon as it is searched")
+	var globs globList
+	flagSet.Var(&globs, "glob", "with -r, only search files matching `GLOB`, or skip them if it starts with !")
+	if err := flagSet.Parse(expandShortFlags(flagSet, args)); err != nil {
+		return ExitError
+	}
+	var pattern, source string
+	var paths []string
+	switch {
+	case *query != "" && *queryFile != "":
+		fmt.Fprintln(errOutput, "grep: --query and -f can't be used together")
+		return ExitError
+	case *query != "":
+		pattern, source, paths = *query, "query", flagSet.Args()
+	case *queryFile != "":
+		data, err := os.ReadFile(*queryFile)
+		if err != nil {
+			fmt.Fprintf(errOutput, "grep: %s\n", describe(err))
+			return ExitError
+		}
+		pattern, source, paths = string(data), *queryFile, flagSet.Args()
+	case flagSet.NArg() < 1:
+		flagSet.Usage()
+		return ExitError
+	default:
+		pattern, paths = flagSet.Arg(0), flagSet.Args()[1:]
+	}
+	if *jsonLines && (*count || *withMatches || *withoutMatch) {
+		fmt.Fprintln(errOutput, "grep: --json can't be used with -c, -l or -L")
+		return ExitError
+	}
+	replace := false
+	flagSet.Visit(func(f *flag.Flag) {
+		replace = replace || f.Name == "replace"
+	})
+	if (*diff || *inPlace) && !replace {
+		fmt.Fprintln(errOutput, "grep: --diff and --in-place need --replace")
+		return ExitError
+	}
+	if *jsonLines && replace {
+		fmt.Fprintln(errOutput, "grep: --json can't be used with --replace")
+		return ExitError
+	}
+	if *diff && (*count || *withMatches || *withoutMatch || *quietFlag || *jsonLines) {
+		fmt.Fprintln(errOutput, "grep: --diff can't be used with -c, -l, -L, -q or --json")
+		return ExitError
+	}
+
+	writer := bufio.NewWriter(output)
+	defer writer.Flush()
+	options := []option{
+		WithReader(input),
+		WithWriter(writer),
+		WithMaxCount(*maxCount),
+	}
+	if *workers > 0 {
+		options = append(options, WithWorkers(*workers))
+	}
+	if *recursive {
+		options = append(options, Recursive())
+	}
+	if *lineNumbers {
+		options = append(options, LineNumbers())
+	}
+	if *byteOffsets {
+		options = append(options, ByteOffsets())
+	}
+	if *onlyMatching {
+		options = append(options, OnlyMatching())
+	}
+	switch showFilenames {
+	case filenamesOn:
+		options = append(options, WithFilenames())
+	case filenamesOff:
+		options = append(options, NoFilenames())
+	}
+	if ColorMode(color) == ColorAuto {
+		// output is wrapped in a bufio.Writer below, so check it here
+		if isTerminal(output) {
+			color = colorFlag(ColorAlways)
+		} else {
+			color = colorFlag(ColorNever)
+		}
+	}
+	options = append(options, WithColor(ColorMode(color)))
+	if spec := os.Getenv("GREP_COLORS"); spec != "" {
+		options = append(options, WithGrepColors(spec))
+	}
+	if *jsonLines {
+		options = append(options, JSON())
+	}
+	if replace {
+		options = append(options, WithReplacement(*replacement))
+	}
+	if *inPlace {
+		options = append(options, ReplaceInPlace())
+	}
+	if *unordered {
+		options = append(options, Unordered())
+	}
+	if *hidden {
+		options = append(options, IncludeHidden())
+	}
+	options = append(options, WithBinaryFiles(BinaryMode(binaryFiles)))
+	for _, glob := range globs {
+		options = append(options, WithGlob(glob))
+	}
+	if source != "" {
+		options = append(options, WithBooleanQuery())
+	}
+	if *extended && !*fixed {
+		options = append(options, WithExtendedRegexp())
+	}
+	// -A and -B take precedence over -C, whatever their order
+	if *around >= 0 {
+		options = append(options, WithContextLines(*around))
+	}
+	if *after >= 0 {
+		options = append(options, WithAfterContext(*after))
+	}
+	if *before >= 0 {
+		options = append(options, WithBeforeContext(*before))
+	}
+	if *ignoreCase {
+		options = append(options, IgnoreCase())
+	}
+	if *word {
+		options = append(options, WordRegexp())
+	}
+	if *line {
+		options = append(options, LineRegexp())
+	}
+	switch {
+	case *quietFlag:
+		options = append(options, Quiet())
+	case *withMatches:
+		options = append(options, FilesWithMatches())
+	case *withoutMatch:
+		options = append(options, FilesWithoutMatch())
+	case *count:
+		options = append(options, CountLines())
+	case *diff:
+		options = append(options, DiffReplacements())
+	}
+	var reported error
+	report := func(err error) {
+		if reported == nil {
+			reported = err
+		}
+		var pathErr *fs.PathError
+		if !*silent || !errors.As(err, &pathErr) {
+			writer.Flush()
+			fmt.Fprintf(errOutput, "grep: %s\n", describe(err))
+		}
+	}
+	options = append(options, WithErrorHandler(report))
+	searcher := NewSearcher(options...)
+	if _, err := searcher.compile(pattern); err != nil {
+		var queryErr *QueryError
+		if errors.As(err, &queryErr) {
+			fmt.Fprintf(errOutput, "grep: %s:%v\n", source, err)
+		} else {
+			fmt.Fprintf(errOutput, "grep: %v\n", err)
+		}
+		return ExitError
+	}
+
+	// errors about single files were reported already
+	if err := searcher.SearchFiles(pattern, paths...); err != nil && reported == nil {
+		report(err)
+	}
+	switch {
+	case *quietFlag && searcher.Matches() > 0:
+		return ExitMatch
+	case reported != nil:
+		return ExitError
+	case searcher.Matches() > 0:
+		return ExitMatch
+	}
+	return ExitNoMatch
+}
+
+// expandShortFlags splits combined single-letter flags like -nbo and -m1, as
+// GNU grep accepts them, into flags the flag package understands.
+func expandShortFlags(flagSet *flag.FlagSet, args []string) []string {
+	expanded := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" || !strings.HasPrefix(arg, "-") || len(arg) < 2 {
+			return append(expanded, args[i:]...)
+		}
+		if f := flagSet.Lookup(strings.TrimLeft(arg, "-")); f != nil || strings.Contains(arg, "=") {
+			expanded = append(expanded, arg)
+			if f != nil && !isBoolFlag(f) && i+1 < len(args) {
+				i++
+				expanded = append(expanded, args[i])
+			}
+			continue
+		}
+		split := []string{}
+		for j := 1; j < len(arg); j++ {
+			f := flagSet.Lookup(arg[j : j+1])
+			if f == nil {
+				// let the flag package report it
+				split = []string{arg}
+				break
+			}
+			split = append(split, "-"+arg[j:j+1])
+			if !isBoolFlag(f) {
+				if j+1 < len(arg) {
+					split = append(split, arg[j+1:])
+				} else if i+1 < len(args) {
+					i++
+					split = append(split, args[i])
+				}
+				break
+			}
+		}
+		expanded = append(expanded, split...)
+	}
+	return expanded
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	boolFlag, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && boolFlag.IsBoolFlag()
+}
+
+type filenames int
+
+const (
+	filenamesImplied filenames = iota
+	filenamesOn
+	filenamesOff
+)
+
+// filenameFlag implements -H and -h, the last one given winning.
+type filenameFlag struct {
+	value *filenames
+	on    bool
+}
+
+func (f filenameFlag) IsBoolFlag() bool { return true }
+
+func (f filenameFlag) String() string { return "" }
+
+func (f filenameFlag) Set(value string) error {
+	set, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	if set == f.on {
+		*f.value = filenamesOn
+	} else {
+		*f.value = filenamesOff
+	}
+	return nil
+}
+
+// colorFlag accepts --color alone, meaning auto, as GNU grep does.
+type colorFlag ColorMode
+
+func (c *colorFlag) IsBoolFlag() bool { return true }
+
+func (c *colorFlag) String() string {
+	switch ColorMode(*c) {
+	case ColorAlways:
+		return "always"
+	case ColorAuto:
+		return "auto"
+	}
+	return "never"
+}
+
+func (c *colorFlag) Set(value string) error {
+	switch value {
+	case "never", "no", "none":
+		*c = colorFlag(ColorNever)
+	case "always", "yes", "force":
+		*c = colorFlag(ColorAlways)
+	case "auto", "tty", "if-tty", "true":
+		*c = colorFlag(ColorAuto)
+	default:
+		return fmt.Errorf("invalid argument %q for --color", value)
+	}
+	return nil
+}
+
+// binaryFlag implements --binary-files.
+type binaryFlag BinaryMode
+
+func (b *binaryFlag) String() string {
+	switch BinaryMode(*b) {
+	case BinaryText:
+		return "text"
+	case BinaryWithoutMatch:
+		return "without-match"
+	}
+	return "binary"
+}
+
+func (b *binaryFlag) Set(value string) error {
+	switch value {
+	case "binary":
+		*b = binaryFlag(BinaryMatches)
+	case "text":
+		*b = binaryFlag(BinaryText)
+	case "without-match":
+		*b = binaryFlag(BinaryWithoutMatch)
+	default:
+		return errors.New("unknown binary-files type")
+	}
+	return nil
+}
+
+// binaryModeFlag implements -a and -I, which set --binary-files.
+type binaryModeFlag struct {
+	value *binaryFlag
+	mode  BinaryMode
+}
+
+func (f binaryModeFlag) IsBoolFlag() bool { return true }
+
+func (f binaryModeFlag) String() string { return "" }
+
+func (f binaryModeFlag) Set(value string) error {
+	set, err := strconv.ParseBool(value)
+	if set && err == nil {
+		*f.value = binaryFlag(f.mode)
+	}
+	return err
+}
+
+// globList collects the values of a repeated flag.
+type globList []string
+
+func (globs *globList) String() string {
+	return strings.Join(*globs, ",")
+}
+
+func (globs *globList) Set(glob string) error {
+	*globs = append(*globs, glob)
+	return nil
+}
+
+// describe formats path errors the way GNU grep does, as "path: reason".
+func describe(err error) string {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return fmt.Sprintf("%s: %v", pathErr.Path, pathErr.Err)
+	}
+	return err.Error()
+}
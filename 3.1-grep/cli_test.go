@@ -0,0 +1,123 @@
+package grep_test
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"grep"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+var cliCases = []struct {
+	name  string
+	args  string
+	stdin string
+}{
+	{"stdin", "apple", "apple\npear\npineapple\n"},
+	{"stdin-dash", "pear -", "apple\npear\n"},
+	{"one-file", "apple testdata/fruits.txt", ""},
+	{"two-files", "-i apple testdata/fruits.txt testdata/vegetables.txt", ""},
+	{"file-and-stdin", "e testdata/vegetables.txt -", "pear\nfig\n"},
+	{"no-match", "kiwi testdata/fruits.txt", ""},
+	{"quiet", "-q apple testdata/fruits.txt", ""},
+	{"quiet-no-match", "-q kiwi testdata/fruits.txt", ""},
+	{"quiet-match-before-error", "-q apple testdata/fruits.txt testdata/missing.txt", ""},
+	{"missing-file", "apple testdata/missing.txt testdata/fruits.txt", ""},
+	{"silent-missing-file", "-s apple testdata/missing.txt testdata/fruits.txt", ""},
+	{"count", "-c apple testdata/fruits.txt", ""},
+	{"count-two-files", "-c -i apple testdata/fruits.txt testdata/vegetables.txt", ""},
+	{"count-max", "-c -m 2 apple testdata/fruits.txt", ""},
+	{"count-max-zero", "-c -m 0 apple testdata/fruits.txt", ""},
+	{"files-with-matches", "-l a testdata/fruits.txt testdata/vegetables.txt -", "fig\n"},
+	{"files-without-match", "-L apple testdata/fruits.txt testdata/vegetables.txt", ""},
+	{"max-count", "-m 2 apple testdata/fruits.txt", ""},
+	{"max-count-zero", "-m 0 apple testdata/fruits.txt", ""},
+	{"extended-whole-line", "-E -x a.*e testdata/fruits.txt", ""},
+	{"after-context", "-A 1 a testdata/context.txt", ""},
+	{"before-context", "-B 3 a4 testdata/context.txt", ""},
+	{"overlapping-context", "-C 1 a testdata/context.txt", ""},
+	{"merged-context", "-C 2 a testdata/context.txt", ""},
+	{"zero-context", "-A 0 a testdata/context.txt", ""},
+	{"context-precedence", "-A 0 -C 1 a testdata/context.txt", ""},
+	{"context-two-files", "-B 2 e testdata/context.txt testdata/context.txt", ""},
+	{"context-max-count", "-m 1 -A 3 a testdata/context.txt", ""},
+	{"recursive", "-r --glob *.txt -i apple testdata", ""},
+	{"recursive-file", "-r leek testdata/vegetables.txt", ""},
+	{"line-numbers", "-n apple testdata/fruits.txt", ""},
+	{"byte-offsets", "-b -n apple testdata/fruits.txt", ""},
+	{"with-filename", "-H apple testdata/fruits.txt", ""},
+	{"no-filename", "-h apple testdata/fruits.txt testdata/vegetables.txt", ""},
+	{"last-filename-flag-wins", "-h -H apple -", "apple\n"},
+	{"only-matching", "-o -b -E p+ testdata/fruits.txt", ""},
+	{"only-matching-context", "-o -n -A 1 a testdata/context.txt", ""},
+	{"combined-flags", "-nbo -m1 apple testdata/fruits.txt", ""},
+	{"color", "--color=always -n -i -E p(l|i) testdata/fruits.txt testdata/vegetables.txt", ""},
+	{"color-context", "--color=always -C 1 a2 testdata/context.txt", ""},
+	{"color-only-matching", "--color=always -o -H e testdata/vegetables.txt", ""},
+	{"color-count", "--color=always -c e testdata/vegetables.txt testdata/fruits.txt", ""},
+	{"color-auto", "--color a testdata/vegetables.txt", ""},
+	{"color-never", "--color=never a testdata/vegetables.txt", ""},
+	{"bad-color", "--color=sometimes a testdata/vegetables.txt", ""},
+	{"binary", "a", "xa\x00y\nab\n"},
+	{"binary-invalid-utf8", "caf", "caf\xe9\n"},
+	{"binary-count", "-c a", "xa\x00y\nab\n"},
+	{"binary-text", "-a -n a", "xa\x00y\nab\n"},
+	{"binary-without-match", "-I a", "xa\x00y\nab\n"},
+	{"binary-files-flag", "--binary-files=text a", "xa\x00y\nab\n"},
+	{"bad-binary-files", "--binary-files=data a", ""},
+	{"json-with-count", "--json -c a testdata/fruits.txt", ""},
+	{"query", "--query NOT(a) testdata/fruits.txt testdata/vegetables.txt", ""},
+	{"query-file", "-n -f testdata/query.q testdata/fruits.txt testdata/vegetables.txt", ""},
+	{"query-color", "--color=always -f testdata/query.q testdata/fruits.txt", ""},
+	{"bad-query", "--query (pie)OR( testdata/fruits.txt", ""},
+	{"bad-query-file", "-f testdata/bad-query.q testdata/fruits.txt", ""},
+	{"replace", "-n -E --replace=<$1> a([0-9]) testdata/context.txt", ""},
+	{"replace-only-matching", "-o -E --replace=${digit}. a(?P<digit>[0-9]) testdata/context.txt", ""},
+	{"replace-color", "--color=always --replace=A a testdata/context.txt", ""},
+	{"diff", "--diff -E --replace=A$1 a([1-3]) testdata/context.txt", ""},
+	{"diff-hunks", "--diff --replace=A a testdata/context.txt testdata/fruits.txt", ""},
+	{"diff-stdin", "--diff --replace=X x -", "x\ny\n"},
+	{"diff-without-replace", "--diff a testdata/context.txt", ""},
+	{"json-with-replace", "--json --replace=X a testdata/fruits.txt", ""},
+	{"in-place-stdin", "--in-place --replace=y x", "x\n"},
+	{"replace-query", "--replace=x --query=a testdata/context.txt", ""},
+	{"bad-regexp", "-E apple( testdata/fruits.txt", ""},
+	{"no-pattern", "", ""},
+	{"unknown-flag", "-Z apple", ""},
+}
+
+func TestCLI(t *testing.T) {
+	t.Parallel()
+	for _, tc := range cliCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			var args []string
+			if tc.args != "" {
+				args = strings.Split(tc.args, " ")
+			}
+			stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+			status := grep.RunCLI(args, strings.NewReader(tc.stdin), stdout, stderr)
+			got := fmt.Sprintf("%s-- stderr --\n%s-- exit status %d --\n", stdout, stderr, status)
+
+			golden := filepath.Join("testdata", "golden", tc.name+".golden")
+			if *update {
+				if err := os.WriteFile(golden, []byte(got), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != string(want) {
+				t.Errorf("grep %s\nwant:\n%s\ngot:\n%s", tc.args, want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,10 @@
+package main
+
+import (
+	"grep"
+	"os"
+)
+
+func main() {
+	os.Exit(grep.RunCLI(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
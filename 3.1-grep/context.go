@@ -0,0 +1,80 @@
+package grep
+
+// WithBeforeContext prints n lines of context before each selected line (-B).
+func WithBeforeContext(n int) option {
+	return func(searcher *Searcher) {
+		searcher.before = n
+	}
+}
+
+// WithAfterContext prints n lines of context after each selected line (-A).
+func WithAfterContext(n int) option {
+	return func(searcher *Searcher) {
+		searcher.after = n
+	}
+}
+
+// WithContextLines prints n lines of context around each selected line (-C).
+func WithContextLines(n int) option {
+	return func(searcher *Searcher) {
+		searcher.before = n
+		searcher.after = n
+	}
+}
+
+// grouped reports whether context was asked for, even zero lines of it, in
+// which case GNU grep separates non-adjacent groups of lines with "--".
+func (searcher *Searcher) grouped() bool {
+	return searcher.before >= 0 || searcher.after >= 0
+}
+
+type numberedLine struct {
+	number    int
+	offset    int64
+	text      []byte
+	noNewline bool
+}
+
+// ring keeps the last lines pushed, up to its capacity, reusing their
+// buffers so that before context takes bounded memory however long the input.
+type ring struct {
+	lines []numberedLine
+	start int
+	size  int
+}
+
+func newRing(capacity int) *ring {
+	if capacity < 0 {
+		capacity = 0
+	}
+	return &ring{lines: make([]numberedLine, capacity)}
+}
+
+func (r *ring) push(line numberedLine) {
+	if len(r.lines) == 0 {
+		return
+	}
+	i := (r.start + r.size) % len(r.lines)
+	if r.size == len(r.lines) {
+		r.start = (r.start + 1) % len(r.lines)
+	} else {
+		r.size++
+	}
+	r.lines[i].number = line.number
+	r.lines[i].offset = line.offset
+	r.lines[i].text = append(r.lines[i].text[:0], line.text...)
+	r.lines[i].noNewline = line.noNewline
+}
+
+// drain passes the lines to f, oldest first, and empties the ring.
+func (r *ring) drain(f func(line numberedLine) error) error {
+	for r.size > 0 {
+		line := r.lines[r.start]
+		r.start = (r.start + 1) % len(r.lines)
+		r.size--
+		if err := f(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,24 +1,96 @@
 package grep
 
 import (
-	"bufio"
-	"fmt"
+	"context"
 	"io"
+	"io/fs"
 	"os"
-	"strings"
+	"regexp"
+	"runtime"
+	"strconv"
+	"time"
+)
+
+type outputMode int
+
+const (
+	printLines outputMode = iota
+	countLines
+	filesWithMatches
+	filesWithoutMatch
+	quiet
+	printDiff
 )
 
 type Searcher struct {
-	reader io.Reader
-	writer io.Writer
+	reader    io.Reader
+	writer    io.Writer
+	label     string
+	filenames bool
+	// filenamesSet is set when filenames was chosen rather than implied by
+	// the number of inputs
+	filenamesSet bool
+	lineNumbers  bool
+	byteOffsets  bool
+	onlyMatching bool
+	color        ColorMode
+	colors       palette
+	// palette is colors, or empty when not coloring this output
+	palette palette
+	// out is reused to build each line of output
+	out        []byte
+	extended   bool
+	ignoreCase bool
+	wordRegexp bool
+	lineRegexp bool
+	// query is set when patterns are boolean queries
+	query       bool
+	replace     bool
+	replacement string
+	inPlace     bool
+	// diffLines keeps the lines of the current input to diff
+	diffLines []Match
+	mode      outputMode
+	maxCount  int
+	recursive bool
+	workers   int
+	unordered bool
+	globs     []ignorePattern
+	hidden    bool
+	binary    bool
+	// binaryFiles is what to do with inputs that look binary
+	binaryFiles BinaryMode
+	// handleError is given errors about single files by SearchFS
+	handleError func(error)
+	handler     func(Match) error
+	// json prints with --json when set
+	json   *jsonPrinter
+	ctx    context.Context
+	before int
+	after  int
+	// printed is set once any line was printed, and lastPrinted is the number
+	// of the last line printed from the current input, for group separators
+	printed     bool
+	lastPrinted int
+	// the last compiled pattern, reused while the pattern doesn't change
+	pattern  string
+	compiled matcher
+	matches  int
 }
 
 type option func(*Searcher)
 
 func NewSearcher(options ...option) *Searcher {
 	searcher := &Searcher{
-		reader: os.Stdin,
-		writer: os.Stdout,
+		reader:   os.Stdin,
+		writer:   os.Stdout,
+		label:    "(standard input)",
+		maxCount: -1,
+		workers:  runtime.GOMAXPROCS(0),
+		ctx:      context.Background(),
+		colors:   parseGrepColors(defaultGrepColors),
+		before:   -1,
+		after:    -1,
 	}
 	for _, optfunc := range options {
 		optfunc(searcher)
@@ -38,6 +110,93 @@ func WithReader(reader io.Reader) option {
 	}
 }
 
+// WithLabel names the input in file name prefixes and file listings.
+func WithLabel(label string) option {
+	return func(searcher *Searcher) {
+		searcher.label = label
+	}
+}
+
+// WithFilenames prefixes each output line with the label of the input.
+func WithFilenames() option {
+	return func(searcher *Searcher) {
+		searcher.filenames = true
+		searcher.filenamesSet = true
+	}
+}
+
+// WithExtendedRegexp interprets patterns as RE2 regular expressions (-E).
+func WithExtendedRegexp() option {
+	return func(searcher *Searcher) {
+		searcher.extended = true
+	}
+}
+
+// WithFixedStrings interprets patterns as plain strings (-F), the default.
+func WithFixedStrings() option {
+	return func(searcher *Searcher) {
+		searcher.extended = false
+	}
+}
+
+func IgnoreCase() option {
+	return func(searcher *Searcher) {
+		searcher.ignoreCase = true
+	}
+}
+
+// WordRegexp only matches whole words, neither preceded nor followed by a
+// letter, a digit or an underscore (-w).
+func WordRegexp() option {
+	return func(searcher *Searcher) {
+		searcher.wordRegexp = true
+	}
+}
+
+// LineRegexp only matches whole lines (-x).
+func LineRegexp() option {
+	return func(searcher *Searcher) {
+		searcher.lineRegexp = true
+	}
+}
+
+// WithMaxCount stops reading the input after n selected lines (-m).
+func WithMaxCount(n int) option {
+	return func(searcher *Searcher) {
+		searcher.maxCount = n
+	}
+}
+
+// CountLines prints the number of selected lines instead of the lines (-c).
+func CountLines() option {
+	return func(searcher *Searcher) {
+		searcher.mode = countLines
+	}
+}
+
+// FilesWithMatches prints the label of the input if any line is selected
+// (-l).
+func FilesWithMatches() option {
+	return func(searcher *Searcher) {
+		searcher.mode = filesWithMatches
+	}
+}
+
+// FilesWithoutMatch prints the label of the input if no line is selected
+// (-L).
+func FilesWithoutMatch() option {
+	return func(searcher *Searcher) {
+		searcher.mode = filesWithoutMatch
+	}
+}
+
+// Quiet prints nothing and stops at the first selected line (-q).
+func Quiet() option {
+	return func(searcher *Searcher) {
+		searcher.mode = quiet
+	}
+}
+
 func (searcher *Searcher) WithWriter(writer io.Writer) *Searcher {
 	searcher.writer = writer
 	return searcher
@@ -48,12 +207,167 @@ func (searcher *Searcher) WithReader(reader io.Reader) *Searcher {
 	return searcher
 }
 
-func (searcher *Searcher) Search(what string) {
-	scanner := bufio.NewScanner(searcher.reader)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.Contains(line, what) {
-			fmt.Fprintln(searcher.writer, line)
+func (searcher *Searcher) WithLabel(label string) *Searcher {
+	searcher.label = label
+	return searcher
+}
+
+// Matches returns the number of lines selected by the last Search.
+func (searcher *Searcher) Matches() int {
+	return searcher.matches
+}
+
+// Compile turns a pattern into a regular expression according to the
+// searcher's matching options. Whole words are left out, as a regular
+// expression can't tell them apart beyond ASCII; searches check them.
+func (searcher *Searcher) Compile(pattern string) (*regexp.Regexp, error) {
+	expr := pattern
+	if !searcher.extended {
+		expr = regexp.QuoteMeta(expr)
+	}
+	if searcher.lineRegexp {
+		expr = `^(?:` + expr + `)$`
+	}
+	if searcher.ignoreCase {
+		expr = `(?i)` + expr
+	}
+	return regexp.Compile(expr)
+}
+
+// compileRegexp compiles a pattern into a matcher of whole words with -w.
+func (searcher *Searcher) compileRegexp(pattern string) (regexpMatcher, error) {
+	re, err := searcher.Compile(pattern)
+	if err != nil {
+		return regexpMatcher{}, err
+	}
+	m := regexpMatcher{re: re}
+	if searcher.wordRegexp && !searcher.lineRegexp {
+		m.words, err = newWordFinder(re)
+	}
+	return m, err
+}
+
+// compile turns a pattern, or a query WithBooleanQuery, into a matcher.
+func (searcher *Searcher) compile(pattern string) (matcher, error) {
+	if searcher.compiled != nil && searcher.pattern == pattern {
+		return searcher.compiled, nil
+	}
+	var compiled matcher
+	if searcher.query && searcher.replace {
+		return nil, errReplaceQuery
+	}
+	if searcher.query {
+		query, err := searcher.parseQuery(pattern)
+		if err != nil {
+			return nil, err
+		}
+		compiled = query
+	} else {
+		m, err := searcher.compileRegexp(pattern)
+		if err != nil {
+			return nil, err
 		}
+		compiled = m
+	}
+	searcher.pattern, searcher.compiled = pattern, compiled
+	return compiled, nil
+}
+
+// printsLines reports whether selected lines are printed, as they are or in a
+// diff.
+func (searcher *Searcher) printsLines() bool {
+	return searcher.mode == printLines || searcher.mode == printDiff
+}
+
+// limit returns how many selected lines to read before stopping, or -1.
+func (searcher *Searcher) limit() int {
+	switch searcher.mode {
+	case filesWithMatches, filesWithoutMatch, quiet:
+		return 1
+	}
+	return searcher.maxCount
+}
+
+// Search prints the lines of the input that match the pattern, or their
+// count or the label of the input, depending on the output mode.
+func (searcher *Searcher) Search(what string) error {
+	searcher.matches = 0
+	compiled, err := searcher.compile(what)
+	if err != nil {
+		return err
+	}
+	if searcher.inPlace {
+		return &fs.PathError{Op: "rewrite", Path: searcher.label, Err: errNotOnDisk}
+	}
+	searcher.lastPrinted = -1
+	searcher.useColors()
+	if searcher.json != nil {
+		searcher.startJSON()
+	}
+	started := time.Now()
+	lines := newLineScanner(searcher.reader)
+	r := result{label: searcher.label, searched: true}
+	r.matches, err = searcher.scan(compiled, lines, searcher.label, searcher.deliver)
+	r.binary, r.binaryOffset = lines.binary, lines.binaryOffset
+	r.bytes, r.elapsed = lines.next, time.Since(started)
+	searcher.matches = r.matches
+	if err != nil {
+		return err
+	}
+	if err := searcher.finish(r); err != nil {
+		return err
+	}
+	if searcher.json != nil && searcher.handler == nil {
+		return searcher.summarizeJSON()
+	}
+	return nil
+}
+
+// deliver passes a match to the match handler, or prints it.
+func (searcher *Searcher) deliver(m Match) error {
+	switch {
+	case searcher.handler != nil:
+		return searcher.handler(m)
+	case searcher.json != nil:
+		return searcher.printJSON(m)
+	case searcher.mode == printDiff:
+		searcher.diffLines = append(searcher.diffLines, m)
+		return nil
+	}
+	return searcher.printMatch(m)
+}
+
+// finish prints the count or the label of an input once it is searched, in
+// the modes that do, or says that a binary input matches.
+func (searcher *Searcher) finish(r result) error {
+	if searcher.handler != nil || searcher.maxCount == 0 {
+		return nil
+	}
+	if searcher.json != nil && searcher.mode == printLines {
+		return searcher.endJSON(r)
+	}
+	if searcher.mode == printDiff && !r.binary {
+		return searcher.printDiff(r.label)
+	}
+	label, matches := r.label, r.matches
+	p := searcher.palette
+	out := searcher.out[:0]
+	switch {
+	case searcher.mode == countLines && searcher.filenames:
+		out = p.paint(out, p.filename, label)
+		out = p.paint(out, p.separator, ":")
+		out = strconv.AppendInt(out, int64(matches), 10)
+	case searcher.mode == countLines:
+		out = strconv.AppendInt(out, int64(matches), 10)
+	case searcher.printsLines() && r.binary && matches > 0:
+		out = append(out, "Binary file "+label+" matches"...)
+	case searcher.mode == filesWithMatches && matches > 0,
+		searcher.mode == filesWithoutMatch && matches == 0:
+		out = p.paint(out, p.filename, label)
+	default:
+		return nil
 	}
+	searcher.out = append(out, '\n')
+	_, err := searcher.writer.Write(searcher.out)
+	return err
 }
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"grep"
 	"io"
+	"strings"
 	"testing"
 )
 
@@ -29,7 +30,9 @@ func TestGrepWithOptions(t *testing.T) {
 		grep.WithWriter(io.Writer(mockWriter)),
 		grep.WithReader(io.Reader(mockReader)),
 	)
-	searcher.Search("foo")
+	if err := searcher.Search("foo"); err != nil {
+		t.Fatal(err)
+	}
 	got := mockWriter.String()
 	if got != want {
 		t.Errorf("want: %#v, got: %#v", want, got)
@@ -43,9 +46,148 @@ func TestGrepWithSetterChains(t *testing.T) {
 	searcher := grep.NewSearcher().
 		WithWriter(io.Writer(mockWriter)).
 		WithReader(io.Reader(mockReader))
-	searcher.Search("foo")
+	if err := searcher.Search("foo"); err != nil {
+		t.Fatal(err)
+	}
 	got := mockWriter.String()
 	if got != want {
 		t.Errorf("want: %#v, got: %#v", want, got)
 	}
 }
+
+func TestGrepModes(t *testing.T) {
+	t.Parallel()
+	input := `foo
+Foo bar
+foobar
+kung-foo
+a.c
+abc
+café au lait
+a -x
+`
+	tests := []struct {
+		name     string
+		pattern  string
+		searcher *grep.Searcher
+		want     string
+	}{
+		{"fixed strings by default", "a.c", grep.NewSearcher(), "a.c\n"},
+		{"explicit fixed strings", "a.c", grep.NewSearcher(grep.WithExtendedRegexp(), grep.WithFixedStrings()), "a.c\n"},
+		{"extended", "a.c", grep.NewSearcher(grep.WithExtendedRegexp()), "a.c\nabc\n"},
+		{"extended alternation", "^(abc|foobar)$", grep.NewSearcher(grep.WithExtendedRegexp()), "foobar\nabc\n"},
+		{"ignore case", "FOO", grep.NewSearcher(grep.IgnoreCase()), "foo\nFoo bar\nfoobar\nkung-foo\n"},
+		{"whole words", "foo", grep.NewSearcher(grep.WordRegexp()), "foo\nkung-foo\n"},
+		{"whole words beyond ASCII", "café", grep.NewSearcher(grep.WordRegexp()), "café au lait\n"},
+		{"whole words of non-word runes", "-x", grep.NewSearcher(grep.WordRegexp()), "a -x\n"},
+		{"whole words ignoring case", "foo", grep.NewSearcher(grep.WordRegexp(), grep.IgnoreCase()), "foo\nFoo bar\nkung-foo\n"},
+		{"whole lines", "foo", grep.NewSearcher(grep.LineRegexp()), "foo\n"},
+		{"whole lines of an alternation", "foo|abc", grep.NewSearcher(grep.WithExtendedRegexp(), grep.LineRegexp()), "foo\nabc\n"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			output := &bytes.Buffer{}
+			searcher := tt.searcher.
+				WithReader(bytes.NewBufferString(input)).
+				WithWriter(output)
+			if err := searcher.Search(tt.pattern); err != nil {
+				t.Fatal(err)
+			}
+			if got := output.String(); got != tt.want {
+				t.Errorf("want: %#v, got: %#v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestGrepReturnsCompileErrors(t *testing.T) {
+	t.Parallel()
+	output := &bytes.Buffer{}
+	searcher := grep.NewSearcher(
+		grep.WithReader(bytes.NewBufferString(testInput)),
+		grep.WithWriter(output),
+		grep.WithExtendedRegexp(),
+	)
+	if err := searcher.Search("foo("); err == nil {
+		t.Error("want error for invalid regexp, got nil")
+	}
+	if output.Len() != 0 {
+		t.Errorf("want no output, got %q", output.String())
+	}
+}
+
+func TestGrepContextAtEndOfLongInput(t *testing.T) {
+	t.Parallel()
+	input := strings.Repeat("filler\n", 100000) + "x\nmatch\ny\n"
+	output := &bytes.Buffer{}
+	searcher := grep.NewSearcher(
+		grep.WithReader(strings.NewReader(input)),
+		grep.WithWriter(output),
+		grep.WithBeforeContext(2),
+		grep.WithAfterContext(5),
+	)
+	if err := searcher.Search("match"); err != nil {
+		t.Fatal(err)
+	}
+	want := "filler\nx\nmatch\ny\n"
+	if got := output.String(); got != want {
+		t.Errorf("want: %#v, got: %#v", want, got)
+	}
+}
+
+func TestGrepBinaryFiles(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		input    string
+		searcher *grep.Searcher
+		want     string
+	}{
+		{"NUL byte", "foo\x00\nfoo\n", grep.NewSearcher(), "Binary file (standard input) matches\n"},
+		{"invalid UTF-8", "caf\xe9 foo\n", grep.NewSearcher(), "Binary file (standard input) matches\n"},
+		{"rune cut by the probe", strings.Repeat("a", 8<<10-1) + "é foo\n", grep.NewSearcher(grep.CountLines()), "1\n"},
+		{"no match", "bar\x00\n", grep.NewSearcher(), ""},
+		{"counted", "foo\x00\nfoo\n", grep.NewSearcher(grep.CountLines()), "2\n"},
+		{"as text", "foo\x00\nbar\n", grep.NewSearcher(grep.WithBinaryFiles(grep.BinaryText)), "foo\x00\n"},
+		{"without match", "foo\x00\n", grep.NewSearcher(grep.WithBinaryFiles(grep.BinaryWithoutMatch)), ""},
+		{"listed without match", "foo\x00\n", grep.NewSearcher(grep.WithBinaryFiles(grep.BinaryWithoutMatch), grep.FilesWithoutMatch()), "(standard input)\n"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			output := &bytes.Buffer{}
+			searcher := tt.searcher.
+				WithReader(strings.NewReader(tt.input)).
+				WithWriter(output)
+			if err := searcher.Search("foo"); err != nil {
+				t.Fatal(err)
+			}
+			if got := output.String(); got != tt.want {
+				t.Errorf("want: %#v, got: %#v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestGrepLongLines(t *testing.T) {
+	t.Parallel()
+	long := strings.Repeat("x", 1<<20)
+	input := long + "\n" + long + "match\nmatch\n"
+	output := &bytes.Buffer{}
+	searcher := grep.NewSearcher(
+		grep.WithReader(strings.NewReader(input)),
+		grep.WithWriter(output),
+		grep.LineNumbers(),
+		grep.ByteOffsets(),
+	)
+	if err := searcher.Search("match"); err != nil {
+		t.Fatal(err)
+	}
+	want := "2:1048577:" + long + "match\n3:2097159:match\n"
+	if got := output.String(); got != want {
+		t.Errorf("want %d bytes of output, got %d", len(want), len(got))
+	}
+}
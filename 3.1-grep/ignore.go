@@ -0,0 +1,193 @@
+package grep
+
+import (
+	"bufio"
+	"bytes"
+	"io/fs"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// ignoreFiles are read in every directory walked, later files taking
+// precedence over earlier ones.
+var ignoreFiles = []string{".gitignore", ".ignore"}
+
+// ignorePattern is one line of an ignore file, or one glob.
+type ignorePattern struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// parseIgnorePattern compiles a line in gitignore syntax. It returns false for
+// blank lines, comments and patterns that can't match anything.
+func parseIgnorePattern(line string) (ignorePattern, bool) {
+	var p ignorePattern
+	line = trimTrailingSpace(line)
+	if line == "" || line[0] == '#' {
+		return p, false
+	}
+	if line[0] == '!' {
+		p.negate = true
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return p, false
+	}
+	// a slash anywhere but at the end anchors the pattern to the directory of
+	// the ignore file; otherwise it matches a name at any depth
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	expr := globToRegexp(line)
+	if !anchored && !strings.HasPrefix(line, "**") {
+		expr = `(?:.*/)?` + expr
+	}
+	re, err := regexp.Compile(`^` + expr + `$`)
+	if err != nil {
+		return p, false
+	}
+	p.re = re
+	return p, true
+}
+
+// trimTrailingSpace removes trailing spaces unless they are escaped with a
+// backslash.
+func trimTrailingSpace(line string) string {
+	line = strings.TrimRight(line, "\r")
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+		line = line[:len(line)-1]
+	}
+	return line
+}
+
+// globToRegexp translates a gitignore glob: * and ? don't match a slash, **
+// matches any number of directories, and a backslash escapes the next
+// character.
+func globToRegexp(glob string) string {
+	var expr strings.Builder
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; {
+		case strings.HasPrefix(glob[i:], "**/") && (i == 0 || glob[i-1] == '/'):
+			expr.WriteString(`(?:.*/)?`)
+			i += 2
+		case strings.HasPrefix(glob[i:], "**") && i+2 == len(glob) && (i == 0 || glob[i-1] == '/'):
+			expr.WriteString(`.*`)
+			i++
+		case c == '*':
+			expr.WriteString(`[^/]*`)
+		case c == '?':
+			expr.WriteString(`[^/]`)
+		case c == '[':
+			end := classEnd(glob, i)
+			if end < 0 {
+				expr.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			expr.WriteString("[" + strings.ReplaceAll(class, `[`, `\[`) + "]")
+			i = end
+		case c == '\\' && i+1 < len(glob):
+			i++
+			expr.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		default:
+			expr.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	return expr.String()
+}
+
+// classEnd returns the index of the bracket closing the class at glob[start],
+// or -1.
+func classEnd(glob string, start int) int {
+	i := start + 1
+	if i < len(glob) && glob[i] == '!' {
+		i++
+	}
+	if i < len(glob) && glob[i] == ']' {
+		i++
+	}
+	for ; i < len(glob); i++ {
+		if glob[i] == ']' {
+			return i
+		}
+	}
+	return -1
+}
+
+func (p ignorePattern) match(name string, isDir bool) bool {
+	return (isDir || !p.dirOnly) && p.re.MatchString(name)
+}
+
+// ignoreRules are the patterns of the ignore files in dir.
+type ignoreRules struct {
+	dir      string
+	patterns []ignorePattern
+}
+
+func readIgnoreRules(fsys fs.FS, dir string) ignoreRules {
+	rules := ignoreRules{dir: dir}
+	for _, name := range ignoreFiles {
+		data, err := fs.ReadFile(fsys, path.Join(dir, name))
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			if p, ok := parseIgnorePattern(scanner.Text()); ok {
+				rules.patterns = append(rules.patterns, p)
+			}
+		}
+	}
+	return rules
+}
+
+// contains reports whether name is inside the directory of the rules.
+func (rules ignoreRules) contains(name string) bool {
+	return rules.dir == "." || strings.HasPrefix(name, rules.dir+"/")
+}
+
+// match returns whether name is ignored, and false for decided if no pattern
+// matches it. The last matching pattern wins, so a negation can re-include.
+func (rules ignoreRules) match(name string, isDir bool) (ignored, decided bool) {
+	if rules.dir != "." {
+		name = strings.TrimPrefix(name, rules.dir+"/")
+	}
+	for i := len(rules.patterns) - 1; i >= 0; i-- {
+		if p := rules.patterns[i]; p.match(name, isDir) {
+			return !p.negate, true
+		}
+	}
+	return false, false
+}
+
+// ignoreStack holds the rules of the directories from the root of the walk
+// down to the current one.
+type ignoreStack []ignoreRules
+
+// enter drops the rules of directories that don't contain name, which works
+// because fs.WalkDir visits a directory's entries right after it.
+func (stack ignoreStack) enter(name string) ignoreStack {
+	for len(stack) > 0 && !stack[len(stack)-1].contains(name) {
+		stack = stack[:len(stack)-1]
+	}
+	return stack
+}
+
+// ignored asks the innermost directory first, as rules in deeper ignore files
+// override those higher up.
+func (stack ignoreStack) ignored(name string, isDir bool) bool {
+	for i := len(stack) - 1; i >= 0; i-- {
+		if ignored, decided := stack[i].match(name, isDir); decided {
+			return ignored
+		}
+	}
+	return false
+}
@@ -0,0 +1,231 @@
+package grep
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// JSON prints the selected and context lines as JSON Lines, in the messages
+// of rg --json: begin and end around the lines of each input with any, and a
+// summary of the whole search.
+func JSON() option {
+	return func(searcher *Searcher) {
+		searcher.json = &jsonPrinter{}
+	}
+}
+
+// jsonPrinter keeps the statistics of the input being printed and of the
+// whole search.
+type jsonPrinter struct {
+	buffer  bytes.Buffer
+	started time.Time
+	// begun is set once the begin message of the current input is printed
+	begun bool
+	// input counts what is printed of the current input until its end
+	input jsonStats
+	total jsonStats
+}
+
+type jsonMessage struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+}
+
+type jsonBegin struct {
+	Path jsonData `json:"path"`
+}
+
+type jsonLine struct {
+	Path           jsonData       `json:"path"`
+	Lines          jsonData       `json:"lines"`
+	LineNumber     int            `json:"line_number"`
+	AbsoluteOffset int64          `json:"absolute_offset"`
+	Submatches     []jsonSubmatch `json:"submatches"`
+}
+
+type jsonSubmatch struct {
+	Match jsonData `json:"match"`
+	Start int      `json:"start"`
+	End   int      `json:"end"`
+}
+
+type jsonEnd struct {
+	Path jsonData `json:"path"`
+	// BinaryOffset is where a binary input was found to be binary, or null
+	BinaryOffset *int64    `json:"binary_offset"`
+	Stats        jsonStats `json:"stats"`
+}
+
+type jsonSummary struct {
+	ElapsedTotal jsonDuration `json:"elapsed_total"`
+	Stats        jsonStats    `json:"stats"`
+}
+
+type jsonStats struct {
+	Elapsed           jsonDuration `json:"elapsed"`
+	Searches          int          `json:"searches"`
+	SearchesWithMatch int          `json:"searches_with_match"`
+	BytesSearched     int64        `json:"bytes_searched"`
+	BytesPrinted      int64        `json:"bytes_printed"`
+	MatchedLines      int          `json:"matched_lines"`
+	Matches           int          `json:"matches"`
+}
+
+func (stats *jsonStats) add(other jsonStats) {
+	stats.Elapsed = newJSONDuration(stats.Elapsed.duration + other.Elapsed.duration)
+	stats.Searches += other.Searches
+	stats.SearchesWithMatch += other.SearchesWithMatch
+	stats.BytesSearched += other.BytesSearched
+	stats.BytesPrinted += other.BytesPrinted
+	stats.MatchedLines += other.MatchedLines
+	stats.Matches += other.Matches
+}
+
+type jsonDuration struct {
+	Secs     int64  `json:"secs"`
+	Nanos    int64  `json:"nanos"`
+	Human    string `json:"human"`
+	duration time.Duration
+}
+
+func newJSONDuration(d time.Duration) jsonDuration {
+	return jsonDuration{
+		Secs:     int64(d / time.Second),
+		Nanos:    int64(d % time.Second),
+		Human:    strconv.FormatFloat(d.Seconds(), 'f', 6, 64) + "s",
+		duration: d,
+	}
+}
+
+// jsonData is text as {"text": ...}, or, if it isn't valid UTF-8, as
+// {"bytes": ...} in base64.
+type jsonData string
+
+func (data jsonData) MarshalJSON() ([]byte, error) {
+	if utf8.ValidString(string(data)) {
+		return marshalJSON(struct {
+			Text string `json:"text"`
+		}{string(data)})
+	}
+	return marshalJSON(struct {
+		Bytes string `json:"bytes"`
+	}{base64.StdEncoding.EncodeToString([]byte(data))})
+}
+
+// marshalJSON is json.Marshal without escaping <, > and &, as rg doesn't.
+func marshalJSON(v interface{}) ([]byte, error) {
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	err := encoder.Encode(v)
+	return bytes.TrimSuffix(buffer.Bytes(), []byte{'\n'}), err
+}
+
+// startJSON starts the statistics of a search.
+func (searcher *Searcher) startJSON() {
+	printer := searcher.json
+	printer.started, printer.begun = time.Now(), false
+	printer.input, printer.total = jsonStats{}, jsonStats{}
+}
+
+// printJSON prints a match or context message, after the begin message of its
+// input if it is the first.
+func (searcher *Searcher) printJSON(m Match) error {
+	printer := searcher.json
+	if err := searcher.beginJSON(m.Path); err != nil {
+		return err
+	}
+	lines := m.Line
+	if !m.NoNewline {
+		lines += "\n"
+	}
+	line := jsonLine{
+		Path:           jsonData(m.Path),
+		Lines:          jsonData(lines),
+		LineNumber:     m.LineNumber,
+		AbsoluteOffset: m.ByteOffset,
+		Submatches:     make([]jsonSubmatch, 0, len(m.Submatches)),
+	}
+	for _, r := range m.Submatches {
+		line.Submatches = append(line.Submatches, jsonSubmatch{
+			Match: jsonData(m.Line[r.Start:r.End]),
+			Start: r.Start,
+			End:   r.End,
+		})
+	}
+	if m.Context {
+		return searcher.writeJSON("context", line)
+	}
+	printer.input.Matches += len(m.Submatches)
+	return searcher.writeJSON("match", line)
+}
+
+// beginJSON prints the begin message of an input, unless it is printed.
+func (searcher *Searcher) beginJSON(path string) error {
+	printer := searcher.json
+	if printer.begun {
+		return nil
+	}
+	printer.begun = true
+	return searcher.writeJSON("begin", jsonBegin{Path: jsonData(path)})
+}
+
+// endJSON prints the end message of an input if it had lines printed, or if
+// it is binary and matches, and adds its statistics to those of the search.
+func (searcher *Searcher) endJSON(r result) error {
+	printer := searcher.json
+	// lines of binary inputs aren't printed, but their end says where they
+	// were found binary
+	if r.binary && r.matches > 0 {
+		if err := searcher.beginJSON(r.label); err != nil {
+			return err
+		}
+	}
+	stats := printer.input
+	stats.Elapsed = newJSONDuration(r.elapsed)
+	stats.Searches = 1
+	if r.matches > 0 {
+		stats.SearchesWithMatch = 1
+	}
+	stats.BytesSearched = r.bytes
+	stats.MatchedLines = r.matches
+	printer.total.add(stats)
+	var err error
+	if printer.begun {
+		end := jsonEnd{Path: jsonData(r.label), Stats: stats}
+		if r.binary {
+			end.BinaryOffset = &r.binaryOffset
+		}
+		err = searcher.writeJSON("end", end)
+	}
+	printer.begun, printer.input = false, jsonStats{}
+	return err
+}
+
+// summarizeJSON prints the summary message of the search.
+func (searcher *Searcher) summarizeJSON() error {
+	printer := searcher.json
+	stats := printer.total
+	stats.Elapsed = newJSONDuration(stats.Elapsed.duration)
+	return searcher.writeJSON("summary", jsonSummary{
+		ElapsedTotal: newJSONDuration(time.Since(printer.started)),
+		Stats:        stats,
+	})
+}
+
+func (searcher *Searcher) writeJSON(kind string, data interface{}) error {
+	printer := searcher.json
+	printer.buffer.Reset()
+	encoder := json.NewEncoder(&printer.buffer)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(jsonMessage{Type: kind, Data: data}); err != nil {
+		return err
+	}
+	printer.input.BytesPrinted += int64(printer.buffer.Len())
+	_, err := searcher.writer.Write(printer.buffer.Bytes())
+	return err
+}
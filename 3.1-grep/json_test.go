@@ -0,0 +1,122 @@
+package grep_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"grep"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// decodeJSONLines decodes each message, leaving out the times, which vary.
+func decodeJSONLines(t *testing.T, output string) []interface{} {
+	t.Helper()
+	var messages []interface{}
+	for _, line := range strings.SplitAfter(strings.TrimSuffix(output, "\n"), "\n") {
+		var message map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &message); err != nil {
+			t.Fatalf("%q: %v", line, err)
+		}
+		data := message["data"].(map[string]interface{})
+		delete(data, "elapsed_total")
+		if stats, ok := data["stats"].(map[string]interface{}); ok {
+			delete(stats, "elapsed")
+		}
+		messages = append(messages, message)
+	}
+	return messages
+}
+
+func TestJSON(t *testing.T) {
+	t.Parallel()
+	output := &bytes.Buffer{}
+	searcher := grep.NewSearcher(
+		grep.WithWriter(output),
+		grep.JSON(),
+		grep.IgnoreCase(),
+		grep.WithAfterContext(1),
+	)
+	if err := searcher.SearchFiles("apple", "testdata/vegetables.txt", "testdata/fruits.txt"); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"type":"begin","data":{"path":{"text":"testdata/fruits.txt"}}}
+{"type":"match","data":{"path":{"text":"testdata/fruits.txt"},"lines":{"text":"apple\n"},"line_number":1,"absolute_offset":0,"submatches":[{"match":{"text":"apple"},"start":0,"end":5}]}}
+{"type":"context","data":{"path":{"text":"testdata/fruits.txt"},"lines":{"text":"banana\n"},"line_number":2,"absolute_offset":6,"submatches":[]}}
+{"type":"match","data":{"path":{"text":"testdata/fruits.txt"},"lines":{"text":"Apple pie\n"},"line_number":3,"absolute_offset":13,"submatches":[{"match":{"text":"Apple"},"start":0,"end":5}]}}
+{"type":"match","data":{"path":{"text":"testdata/fruits.txt"},"lines":{"text":"pineapple\n"},"line_number":4,"absolute_offset":23,"submatches":[{"match":{"text":"apple"},"start":4,"end":9}]}}
+{"type":"context","data":{"path":{"text":"testdata/fruits.txt"},"lines":{"text":"cherry\n"},"line_number":5,"absolute_offset":33,"submatches":[]}}
+{"type":"match","data":{"path":{"text":"testdata/fruits.txt"},"lines":{"text":"apple\n"},"line_number":6,"absolute_offset":40,"submatches":[{"match":{"text":"apple"},"start":0,"end":5}]}}
+{"type":"end","data":{"path":{"text":"testdata/fruits.txt"},"binary_offset":null,"stats":{"searches":1,"searches_with_match":1,"bytes_searched":46,"bytes_printed":1116,"matched_lines":4,"matches":4}}}
+{"type":"summary","data":{"stats":{"searches":2,"searches_with_match":1,"bytes_searched":65,"bytes_printed":1116,"matched_lines":4,"matches":4}}}
+`
+	if got, want := decodeJSONLines(t, output.String()), decodeJSONLines(t, want); !reflect.DeepEqual(got, want) {
+		t.Errorf("want: %v\ngot:  %v", want, got)
+	}
+}
+
+func TestJSONText(t *testing.T) {
+	t.Parallel()
+	output := &bytes.Buffer{}
+	searcher := grep.NewSearcher(
+		grep.WithReader(strings.NewReader("caf\xe9 <b>\nfoo & <b>\n")),
+		grep.WithWriter(output),
+		grep.WithBinaryFiles(grep.BinaryText),
+		grep.JSON(),
+	)
+	if err := searcher.Search("<b>"); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(output.String(), "\n")
+	for i, want := range []string{
+		`"lines":{"bytes":"Y2Fm6SA8Yj4K"}`,
+		`"lines":{"text":"foo & <b>\n"}`,
+	} {
+		if got := lines[i+1]; !strings.Contains(got, want) {
+			t.Errorf("want %s in %s", want, got)
+		}
+	}
+}
+
+func TestJSONLastLineWithoutNewline(t *testing.T) {
+	t.Parallel()
+	output := &bytes.Buffer{}
+	searcher := grep.NewSearcher(
+		grep.WithReader(strings.NewReader("foo bar\nlast bar")),
+		grep.WithWriter(output),
+		grep.JSON(),
+	)
+	if err := searcher.Search("bar"); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(output.String(), "\n")
+	for i, want := range []string{
+		`"lines":{"text":"foo bar\n"}`,
+		`"lines":{"text":"last bar"}`,
+	} {
+		if got := lines[i+1]; !strings.Contains(got, want) {
+			t.Errorf("want %s in %s", want, got)
+		}
+	}
+}
+
+func TestJSONBinary(t *testing.T) {
+	t.Parallel()
+	output := &bytes.Buffer{}
+	searcher := grep.NewSearcher(
+		grep.WithReader(strings.NewReader("foo\nbar\x00baz\nfoo\n")),
+		grep.WithWriter(output),
+		grep.WithLabel("data.bin"),
+		grep.JSON(),
+	)
+	if err := searcher.Search("foo"); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"type":"begin","data":{"path":{"text":"data.bin"}}}
+{"type":"end","data":{"path":{"text":"data.bin"},"binary_offset":7,"stats":{"searches":1,"searches_with_match":1,"bytes_searched":4,"bytes_printed":53,"matched_lines":1,"matches":0}}}
+{"type":"summary","data":{"stats":{"searches":1,"searches_with_match":1,"bytes_searched":4,"bytes_printed":53,"matched_lines":1,"matches":0}}}
+`
+	if got, want := decodeJSONLines(t, output.String()), decodeJSONLines(t, want); !reflect.DeepEqual(got, want) {
+		t.Errorf("want: %v\ngot:  %v", want, got)
+	}
+}
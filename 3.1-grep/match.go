@@ -0,0 +1,241 @@
+package grep
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+)
+
+// Range is a half-open range of byte offsets into a line.
+type Range struct {
+	Start, End int
+}
+
+// Match is a selected line, or, with Context set, a line of context around
+// selected lines.
+type Match struct {
+	// Path is the label of the input: the file path, or the label of the
+	// reader
+	Path       string
+	LineNumber int
+	// ByteOffset is the offset of the start of the line in the input
+	ByteOffset int64
+	// Line is the line without its newline
+	Line string
+	// NoNewline is set for the last line of an input if it doesn't end with
+	// a newline
+	NoNewline bool
+	// Submatches are the ranges of Line matching the pattern, in order. With
+	// a query, ranges matching different patterns may overlap.
+	Submatches []Range
+	Context    bool
+}
+
+// OnMatch has Search, SearchFiles and SearchFS pass matches and context lines
+// to handle, in the order they would be printed, instead of printing them. An
+// error from handle stops the search and is returned.
+func OnMatch(handle func(Match) error) option {
+	return func(searcher *Searcher) {
+		searcher.handler = handle
+	}
+}
+
+// WithContext stops the search, with the error of ctx, once ctx is done.
+func WithContext(ctx context.Context) option {
+	return func(searcher *Searcher) {
+		searcher.ctx = ctx
+	}
+}
+
+// Stream searches the paths as SearchFiles does, in the background, and sends
+// the matches and context lines on the returned channel. The channel is
+// closed when the search ends, or soon after ctx is done. wait blocks until
+// then and returns the error of the search.
+func (searcher *Searcher) Stream(ctx context.Context, what string, paths ...string) (matches <-chan Match, wait func() error) {
+	stream := make(chan Match)
+	done := make(chan struct{})
+	background := *searcher
+	background.ctx = ctx
+	background.handler = func(m Match) error {
+		select {
+		case stream <- m:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	var err error
+	go func() {
+		defer close(done)
+		defer close(stream)
+		err = background.SearchFiles(what, paths...)
+	}()
+	return stream, func() error {
+		<-done
+		return err
+	}
+}
+
+// scan passes the selected lines to emit, along with their context lines when
+// lines are printed, and returns the number of lines selected. It emits
+// nothing when only counting or listing files, unless there is a match
+// handler, and nothing from binary inputs unless they are searched as text.
+func (searcher *Searcher) scan(m matcher, lines *lineScanner, label string, emit func(Match) error) (int, error) {
+	if searcher.maxCount == 0 {
+		// like GNU grep, -m 0 stops before reading anything
+		return 0, nil
+	}
+	lines.binary = searcher.binaryFiles != BinaryText && lines.looksBinary()
+	if lines.binary && searcher.binaryFiles == BinaryWithoutMatch {
+		return 0, nil
+	}
+	limit := searcher.limit()
+	if lines.binary && searcher.printsLines() {
+		// one selected line is enough to say that the file matches
+		limit = 1
+	}
+	emitting := !lines.binary && (searcher.handler != nil || searcher.printsLines())
+	withContext := !lines.binary && searcher.printsLines()
+	beforeLines, afterLines := searcher.before, searcher.after
+	if searcher.mode == printDiff {
+		beforeLines, afterLines = diffContext, diffContext
+	}
+	before := newRing(beforeLines)
+	context := func(line numberedLine) error {
+		return emit(Match{
+			Path:       label,
+			LineNumber: line.number,
+			ByteOffset: line.offset,
+			Line:       string(line.text),
+			NoNewline:  line.noNewline,
+			Context:    true,
+		})
+	}
+	afterLeft := 0
+	matches := 0
+	for {
+		limited := limit >= 0 && matches >= limit
+		if limited && (!withContext || afterLeft == 0) {
+			return matches, nil
+		}
+		if err := searcher.ctx.Err(); err != nil {
+			return matches, err
+		}
+		if !lines.scan() {
+			return matches, lines.err()
+		}
+		line := lines.current
+		// after the last selected line, -m still prints trailing context
+		if !limited && m.match(line.text) {
+			matches++
+			if !emitting {
+				continue
+			}
+			if err := before.drain(context); err != nil {
+				return matches, err
+			}
+			err := emit(Match{
+				Path:       label,
+				LineNumber: line.number,
+				ByteOffset: line.offset,
+				Line:       string(line.text),
+				NoNewline:  line.noNewline,
+				Submatches: m.ranges(line.text, nil),
+			})
+			if err != nil {
+				return matches, err
+			}
+			if afterLines > 0 {
+				afterLeft = afterLines
+			}
+			continue
+		}
+		if withContext && afterLeft > 0 {
+			afterLeft--
+			if err := context(line); err != nil {
+				return matches, err
+			}
+			continue
+		}
+		if withContext {
+			before.push(line)
+		}
+	}
+}
+
+// bufferSize is the size of the buffer lines are read through. Longer lines
+// are put together in a buffer of their own.
+const bufferSize = 64 << 10
+
+// lineScanner splits its input into lines, keeping track of their numbers
+// and offsets. Unlike bufio.ScanLines it keeps carriage returns, as GNU grep
+// does, and it takes lines of any length.
+type lineScanner struct {
+	reader  *bufio.Reader
+	current numberedLine
+	// next is the offset of the next line, and how much of the input was read
+	next int64
+	// long holds the current line when it doesn't fit in the buffer
+	long   []byte
+	failed error
+	// binary is set by scan when the input is taken to be binary, because of
+	// the byte at binaryOffset
+	binary       bool
+	binaryOffset int64
+}
+
+func newLineScanner(reader io.Reader) *lineScanner {
+	return &lineScanner{reader: bufio.NewReaderSize(reader, bufferSize)}
+}
+
+// looksBinary checks what a single read brings of the input, so as not to wait
+// for more of a slow one. It must be called before the first line is scanned.
+func (lines *lineScanner) looksBinary() bool {
+	// a read error is met again by scan
+	lines.reader.Peek(1)
+	n := lines.reader.Buffered()
+	if n > binaryProbe {
+		n = binaryProbe
+	}
+	head, _ := lines.reader.Peek(n)
+	offset := binaryOffset(head)
+	lines.binaryOffset = int64(offset)
+	return offset >= 0
+}
+
+func (lines *lineScanner) scan() bool {
+	if lines.failed != nil {
+		return false
+	}
+	text, err := lines.reader.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		lines.long = append(lines.long[:0], text...)
+		for err == bufio.ErrBufferFull {
+			text, err = lines.reader.ReadSlice('\n')
+			lines.long = append(lines.long, text...)
+		}
+		text = lines.long
+	}
+	if err != nil {
+		lines.failed = err
+		if len(text) == 0 {
+			return false
+		}
+	}
+	lines.current = numberedLine{
+		number:    lines.current.number + 1,
+		offset:    lines.next,
+		text:      bytes.TrimSuffix(text, []byte{'\n'}),
+		noNewline: !bytes.HasSuffix(text, []byte{'\n'}),
+	}
+	lines.next += int64(len(text))
+	return true
+}
+
+func (lines *lineScanner) err() error {
+	if lines.failed == io.EOF {
+		return nil
+	}
+	return lines.failed
+}
@@ -0,0 +1,102 @@
+package grep_test
+
+import (
+	"context"
+	"errors"
+	"grep"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOnMatch(t *testing.T) {
+	t.Parallel()
+	var got []grep.Match
+	searcher := grep.NewSearcher(
+		grep.WithReader(strings.NewReader("one\nfoo and foo\r\nthree\nfour\nfive foo\n")),
+		grep.WithLabel("input"),
+		grep.WithAfterContext(1),
+		grep.OnMatch(func(m grep.Match) error {
+			got = append(got, m)
+			return nil
+		}),
+	)
+	if err := searcher.Search("foo"); err != nil {
+		t.Fatal(err)
+	}
+	want := []grep.Match{
+		{Path: "input", LineNumber: 2, ByteOffset: 4, Line: "foo and foo\r", Submatches: []grep.Range{{0, 3}, {8, 11}}},
+		{Path: "input", LineNumber: 3, ByteOffset: 17, Line: "three", Context: true},
+		{Path: "input", LineNumber: 5, ByteOffset: 28, Line: "five foo", Submatches: []grep.Range{{5, 8}}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want: %+v\ngot:  %+v", want, got)
+	}
+	if searcher.Matches() != 2 {
+		t.Errorf("want 2 matches, got %d", searcher.Matches())
+	}
+}
+
+func TestOnMatchErrorStopsSearch(t *testing.T) {
+	t.Parallel()
+	stop := errors.New("enough")
+	calls := 0
+	searcher := grep.NewSearcher(grep.OnMatch(func(grep.Match) error {
+		calls++
+		return stop
+	}))
+	err := searcher.SearchFiles("apple", "testdata/fruits.txt", "testdata/fruits.txt")
+	if err != stop {
+		t.Errorf("want %v, got %v", stop, err)
+	}
+	if calls != 1 {
+		t.Errorf("want 1 call, got %d", calls)
+	}
+}
+
+func TestStream(t *testing.T) {
+	t.Parallel()
+	searcher := grep.NewSearcher(grep.IgnoreCase())
+	matches, wait := searcher.Stream(context.Background(), "apple", "testdata/fruits.txt", "testdata/vegetables.txt")
+	var got []string
+	for m := range matches {
+		got = append(got, m.Path+":"+m.Line)
+	}
+	if err := wait(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"testdata/fruits.txt:apple",
+		"testdata/fruits.txt:Apple pie",
+		"testdata/fruits.txt:pineapple",
+		"testdata/fruits.txt:apple",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want: %q, got: %q", want, got)
+	}
+}
+
+func TestStreamCancel(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	searcher := grep.NewSearcher(grep.WithWorkers(2))
+	paths := make([]string, 100)
+	for i := range paths {
+		paths[i] = "testdata/fruits.txt"
+	}
+	matches, wait := searcher.Stream(ctx, "apple", paths...)
+	received := 0
+	for range matches {
+		received++
+		if received == 5 {
+			cancel()
+		}
+	}
+	if err := wait(); !errors.Is(err, context.Canceled) {
+		t.Errorf("want %v, got %v", context.Canceled, err)
+	}
+	if received >= 300 {
+		t.Errorf("want the search to stop early, got all %d matches", received)
+	}
+}
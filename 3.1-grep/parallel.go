@@ -0,0 +1,322 @@
+package grep
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+)
+
+// WithWorkers searches up to n files at once.
+func WithWorkers(n int) option {
+	return func(searcher *Searcher) {
+		searcher.workers = n
+	}
+}
+
+// Unordered prints the output for each file as soon as the file is searched,
+// rather than in the order the files were given or found.
+func Unordered() option {
+	return func(searcher *Searcher) {
+		searcher.unordered = true
+	}
+}
+
+// Recursive has SearchFiles search the files under directories, as SearchFS
+// does.
+func Recursive() option {
+	return func(searcher *Searcher) {
+		searcher.recursive = true
+	}
+}
+
+// input is a file to search, or the error met while looking for one.
+type input struct {
+	label string
+	// path is the path of a file on disk, which can be rewritten
+	path string
+	open func() (io.ReadCloser, error)
+	// found is set for files found by walking a directory, which are
+	// skipped if binary
+	found bool
+	err   error
+}
+
+// foundBuffer is how many matches a worker can find ahead of their delivery,
+// which bounds the memory taken by an input with many matches.
+const foundBuffer = 64
+
+// errStopped ends the search of an input when the whole search is stopped.
+var errStopped = errors.New("search stopped")
+
+// result holds what is known of one input once it is searched.
+type result struct {
+	label    string
+	matches  int
+	searched bool
+	binary   bool
+	// binaryOffset is where a binary input was found to be binary
+	binaryOffset int64
+	// bytes is how much of the input was read, in elapsed
+	bytes   int64
+	elapsed time.Duration
+	err     error
+}
+
+type job struct {
+	input input
+	// found streams the matches of the input to the collector, so that
+	// outputs don't interleave, and is closed before result is sent
+	found  chan Match
+	result chan result
+}
+
+// SearchFiles searches the named files, or the reader for "-", with a pool of
+// workers. Without paths it searches the reader, or "." if Recursive. It
+// returns the first error, after giving every error about a single file to
+// the error handler if there is one.
+func (searcher *Searcher) SearchFiles(what string, paths ...string) error {
+	defaulted := len(paths) == 0
+	if defaulted && searcher.recursive {
+		paths = []string{"."}
+	} else if defaulted {
+		paths = []string{stdin}
+	}
+	dirs := make([]bool, len(paths))
+	for i, path := range paths {
+		if searcher.recursive && path != stdin {
+			info, err := os.Stat(path)
+			dirs[i] = err == nil && info.IsDir()
+		}
+		if !searcher.filenamesSet && (dirs[i] || len(paths) > 1) {
+			searcher.filenames = true
+		}
+	}
+	return searcher.run(what, func(send func(input) bool) {
+		for i, path := range paths {
+			path := path
+			switch {
+			case path == stdin:
+				reader := searcher.reader
+				open := func() (io.ReadCloser, error) { return io.NopCloser(reader), nil }
+				if !send(input{label: searcher.label, open: open}) {
+					return
+				}
+			case dirs[i]:
+				prefix := path
+				if defaulted {
+					prefix = ""
+				}
+				onDisk := func(in input) bool {
+					in.path = in.label
+					return send(in)
+				}
+				if !searcher.walkInputs(os.DirFS(path), ".", prefix, onDisk) {
+					return
+				}
+			default:
+				open := func() (io.ReadCloser, error) { return os.Open(path) }
+				if !send(input{label: path, path: path, open: open}) {
+					return
+				}
+			}
+		}
+	})
+}
+
+// run searches the inputs that produce sends, until send returns false, and
+// delivers what is found one whole input at a time, as it is found.
+func (searcher *Searcher) run(what string, produce func(send func(input) bool)) error {
+	if _, err := searcher.compile(what); err != nil {
+		return err
+	}
+	searcher.useColors()
+	if searcher.json != nil {
+		searcher.startJSON()
+	}
+	workers := searcher.workers
+	if workers < 1 {
+		workers = 1
+	}
+	stop := make(chan struct{})
+	jobs := make(chan job, workers)
+	// pending holds the jobs in input order, so they can be collected in
+	// that order
+	pending := make(chan job, 4*workers)
+	// searched holds the jobs in the order they are collected
+	searched := make(chan job, workers)
+
+	go func() {
+		defer close(jobs)
+		defer close(pending)
+		produce(func(in input) bool {
+			j := job{input: in, found: make(chan Match, foundBuffer), result: make(chan result, 1)}
+			if !searcher.unordered {
+				select {
+				case pending <- j:
+				case <-stop:
+					return false
+				}
+			}
+			select {
+			case jobs <- j:
+				return true
+			case <-stop:
+				// no worker will see the job, but it may be pending
+				close(j.found)
+				j.result <- result{}
+				return false
+			}
+		})
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		worker := *searcher
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				if searcher.unordered {
+					// the input is collected while it is searched
+					searched <- j
+				}
+				var r result
+				select {
+				case <-stop:
+				default:
+					r = worker.searchInput(j.input, j.found, stop)
+				}
+				close(j.found)
+				j.result <- r
+			}
+		}()
+	}
+	if searcher.unordered {
+		go func() {
+			wg.Wait()
+			close(searched)
+		}()
+	} else {
+		go func() {
+			for j := range pending {
+				searched <- j
+			}
+			close(searched)
+		}()
+	}
+
+	total := 0
+	var first error
+	stopped := false
+	halt := func() {
+		if !stopped {
+			stopped = true
+			close(stop)
+		}
+	}
+	// jobs are drained to the end so that no goroutine is left blocked;
+	// workers stop sending matches once stopped
+	for j := range searched {
+		if stopped {
+			continue
+		}
+		r, err := searcher.collect(j)
+		total += r.matches
+		if err != nil {
+			first = err
+			halt()
+			continue
+		}
+		if err := searcher.ctx.Err(); err != nil {
+			first = err
+			halt()
+			continue
+		}
+		if r.err != nil {
+			if first == nil {
+				first = r.err
+			}
+			if searcher.handleError == nil {
+				halt()
+				continue
+			}
+			searcher.handleError(r.err)
+		}
+		// files left unsearched would be left unrewritten
+		if searcher.mode == quiet && total > 0 && !searcher.inPlace {
+			halt()
+		}
+	}
+	searcher.matches = total
+	if searcher.json != nil && searcher.handler == nil && !stopped {
+		if err := searcher.summarizeJSON(); err != nil {
+			return err
+		}
+	}
+	return first
+}
+
+// searchInput searches one input, sending what it finds to the collector
+// until stop is closed.
+func (worker *Searcher) searchInput(in input, found chan<- Match, stop <-chan struct{}) result {
+	if in.err != nil {
+		return result{err: in.err}
+	}
+	if worker.inPlace && in.path == "" {
+		return result{err: &fs.PathError{Op: "rewrite", Path: in.label, Err: errNotOnDisk}}
+	}
+	file, err := in.open()
+	if err != nil {
+		return result{err: err}
+	}
+	defer file.Close()
+	started := time.Now()
+	lines := newLineScanner(file)
+	if in.found && !worker.binary && worker.binaryFiles != BinaryText && lines.looksBinary() {
+		return result{}
+	}
+	r := result{label: in.label, searched: true}
+	r.matches, r.err = worker.scan(worker.compiled, lines, in.label, func(m Match) error {
+		select {
+		case found <- m:
+			return nil
+		case <-stop:
+			return errStopped
+		}
+	})
+	r.binary, r.binaryOffset = lines.binary, lines.binaryOffset
+	r.bytes, r.elapsed = lines.next, time.Since(started)
+	if worker.inPlace && r.err == nil && r.matches > 0 && !r.binary {
+		r.err = worker.rewrite(in.path)
+	}
+	return r
+}
+
+// collect delivers the matches of one input as they are found, and then
+// prints its count or label.
+func (searcher *Searcher) collect(j job) (result, error) {
+	searcher.lastPrinted = -1
+	for m := range j.found {
+		if err := searcher.deliver(m); err != nil {
+			return result{}, err
+		}
+	}
+	r := <-j.result
+	if !r.searched || r.err != nil {
+		return r, nil
+	}
+	return r, searcher.finish(r)
+}
+
+// relabel puts prefix back in front of the path of errors from a file system
+// rooted at prefix.
+func relabel(err error, prefix string) error {
+	var pathErr *fs.PathError
+	if prefix == "" || !errors.As(err, &pathErr) {
+		return err
+	}
+	return &fs.PathError{Op: pathErr.Op, Path: joinLabel(prefix, pathErr.Path), Err: pathErr.Err}
+}
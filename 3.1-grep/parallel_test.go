@@ -0,0 +1,180 @@
+package grep_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"grep"
+	"io"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// syntheticTree returns files spread over dirs directories, with a needle
+// every tenth line.
+func syntheticTree(dirs, filesPerDir, linesPerFile int) fstest.MapFS {
+	fsys := fstest.MapFS{}
+	for d := 0; d < dirs; d++ {
+		for f := 0; f < filesPerDir; f++ {
+			var data strings.Builder
+			for l := 0; l < linesPerFile; l++ {
+				if l%10 == 0 {
+					fmt.Fprintf(&data, "line %d with a needle in it\n", l)
+				} else {
+					fmt.Fprintf(&data, "line %d of plain filler text to scan past\n", l)
+				}
+			}
+			name := fmt.Sprintf("dir%03d/file%03d.txt", d, f)
+			fsys[name] = &fstest.MapFile{Data: []byte(data.String())}
+		}
+	}
+	return fsys
+}
+
+func searchTree(t *testing.T, fsys fstest.MapFS, searcher *grep.Searcher) string {
+	t.Helper()
+	output := &bytes.Buffer{}
+	if err := searcher.WithWriter(output).SearchFS(fsys, "needle"); err != nil {
+		t.Fatal(err)
+	}
+	return output.String()
+}
+
+func TestParallelSearchKeepsInputOrder(t *testing.T) {
+	t.Parallel()
+	fsys := syntheticTree(10, 10, 50)
+	want := searchTree(t, fsys, grep.NewSearcher(grep.WithWorkers(1)))
+	got := searchTree(t, fsys, grep.NewSearcher(grep.WithWorkers(8)))
+	if got != want {
+		t.Errorf("output with 8 workers differs from output with 1 worker")
+	}
+	if !strings.HasPrefix(want, "dir000/file000.txt:line 0 with a needle in it\n") {
+		t.Errorf("unexpected output start: %q", want[:50])
+	}
+}
+
+func TestParallelSearchUnorderedDoesNotInterleave(t *testing.T) {
+	t.Parallel()
+	fsys := syntheticTree(10, 10, 50)
+	got := searchTree(t, fsys, grep.NewSearcher(grep.WithWorkers(8), grep.Unordered()))
+	lines := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+	if len(lines) != 10*10*5 {
+		t.Fatalf("want %d lines, got %d", 10*10*5, len(lines))
+	}
+	done := map[string]bool{}
+	previous := ""
+	for _, line := range lines {
+		file := line[:strings.Index(line, ":")]
+		if file != previous {
+			if done[file] {
+				t.Fatalf("output of %s is interleaved with other files", file)
+			}
+			done[file] = true
+			previous = file
+		}
+	}
+	if len(done) != 100 {
+		t.Errorf("want output from 100 files, got %d", len(done))
+	}
+}
+
+func TestParallelSearchSeparatesContextGroupsAcrossFiles(t *testing.T) {
+	t.Parallel()
+	fsys := fstest.MapFS{
+		"a": {Data: []byte("x\nneedle\n")},
+		"b": {Data: []byte("nothing\n")},
+		"c": {Data: []byte("needle\ny\n")},
+	}
+	want := "a-x\na:needle\n--\nc:needle\nc-y\n"
+	got := searchTree(t, fsys, grep.NewSearcher(grep.WithWorkers(3), grep.WithContextLines(1)))
+	if got != want {
+		t.Errorf("want: %#v, got: %#v", want, got)
+	}
+}
+
+func TestSearchFilesReportsErrorsInOrder(t *testing.T) {
+	t.Parallel()
+	var reported []string
+	output := &bytes.Buffer{}
+	searcher := grep.NewSearcher(
+		grep.WithWriter(output),
+		grep.WithWorkers(4),
+		grep.WithErrorHandler(func(err error) {
+			reported = append(reported, fmt.Sprintf("%s after %d bytes", err, output.Len()))
+		}),
+	)
+	err := searcher.SearchFiles("apple", "testdata/fruits.txt", "testdata/missing.txt", "testdata/fruits.txt")
+	if err == nil {
+		t.Fatal("want error for missing file, got nil")
+	}
+	want := []string{"open testdata/missing.txt: no such file or directory after 82 bytes"}
+	if fmt.Sprint(reported) != fmt.Sprint(want) {
+		t.Errorf("want: %q, got: %q", want, reported)
+	}
+	if got := searcher.Matches(); got != 6 {
+		t.Errorf("want 6 matches, got %d", got)
+	}
+}
+
+func TestSearchFilesQuietStopsAtFirstMatch(t *testing.T) {
+	t.Parallel()
+	fsys := syntheticTree(5, 5, 20)
+	searcher := grep.NewSearcher(grep.Quiet(), grep.WithWorkers(4))
+	if got := searchTree(t, fsys, searcher); got != "" {
+		t.Errorf("want no output, got %q", got)
+	}
+	if searcher.Matches() == 0 {
+		t.Error("want a match")
+	}
+}
+
+func TestSearchFilesDeliversMatchesAsFound(t *testing.T) {
+	t.Parallel()
+	reader, writer := io.Pipe()
+	seen := make(chan string, 1)
+	searcher := grep.NewSearcher(grep.OnMatch(func(m grep.Match) error {
+		seen <- m.Line
+		return nil
+	})).WithReader(reader)
+	go func() {
+		writer.Write([]byte("a needle before the end\n"))
+		select {
+		case <-seen:
+			writer.Close()
+		case <-time.After(5 * time.Second):
+			writer.CloseWithError(errors.New("match not delivered before the end of the input"))
+		}
+	}()
+	if err := searcher.SearchFiles("needle"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func benchmarkSearchFS(b *testing.B, searcher *grep.Searcher) {
+	fsys := syntheticTree(50, 40, 200)
+	searcher.WithWriter(io.Discard)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := searcher.SearchFS(fsys, "needle"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSearchFS1Worker(b *testing.B) {
+	benchmarkSearchFS(b, grep.NewSearcher(grep.WithWorkers(1)))
+}
+
+func BenchmarkSearchFS4Workers(b *testing.B) {
+	benchmarkSearchFS(b, grep.NewSearcher(grep.WithWorkers(4)))
+}
+
+func BenchmarkSearchFSDefaultWorkers(b *testing.B) {
+	benchmarkSearchFS(b, grep.NewSearcher())
+}
+
+func BenchmarkSearchFSUnordered(b *testing.B) {
+	benchmarkSearchFS(b, grep.NewSearcher(grep.Unordered()))
+}
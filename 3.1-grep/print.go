@@ -0,0 +1,276 @@
+package grep
+
+import (
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type ColorMode int
+
+const (
+	ColorNever ColorMode = iota
+	ColorAlways
+	// ColorAuto colors output only when writing to a terminal
+	ColorAuto
+)
+
+// defaultGrepColors are GNU grep's default colors, in GREP_COLORS syntax.
+const defaultGrepColors = "ms=01;31:mc=01;31:sl=:cx=:fn=35:ln=32:bn=32:se=36"
+
+// LineNumbers prefixes each output line with its line number (-n).
+func LineNumbers() option {
+	return func(searcher *Searcher) {
+		searcher.lineNumbers = true
+	}
+}
+
+// ByteOffsets prefixes each output line with the offset of the line in its
+// input, or with -o of the match (-b).
+func ByteOffsets() option {
+	return func(searcher *Searcher) {
+		searcher.byteOffsets = true
+	}
+}
+
+// NoFilenames never prefixes output lines with the label of the input (-h).
+func NoFilenames() option {
+	return func(searcher *Searcher) {
+		searcher.filenames = false
+		searcher.filenamesSet = true
+	}
+}
+
+// OnlyMatching prints each non-empty match on a line of its own, instead of
+// the lines (-o).
+func OnlyMatching() option {
+	return func(searcher *Searcher) {
+		searcher.onlyMatching = true
+	}
+}
+
+// WithColor highlights matches, file names, line numbers and separators
+// (--color).
+func WithColor(mode ColorMode) option {
+	return func(searcher *Searcher) {
+		searcher.color = mode
+	}
+}
+
+// WithGrepColors sets the colors used, in the syntax of the GREP_COLORS
+// environment variable of GNU grep, on top of the default colors.
+func WithGrepColors(spec string) option {
+	return func(searcher *Searcher) {
+		searcher.colors = parseGrepColors(defaultGrepColors + ":" + spec)
+	}
+}
+
+// palette holds SGR sequences for the parts of the output, empty for parts
+// printed as they are.
+type palette struct {
+	selectedMatch, contextMatch string
+	selectedLine, contextLine   string
+	filename, lineNumber        string
+	byteOffset, separator       string
+	// noErase leaves out the erase in line sequences that GNU grep adds
+	// after each color
+	noErase bool
+}
+
+// parseGrepColors reads capabilities like "ms=01;31:fn=35:ne", ignoring
+// unknown ones as GNU grep does.
+func parseGrepColors(spec string) palette {
+	var p palette
+	for _, capability := range strings.Split(spec, ":") {
+		name, value, _ := cut(capability, "=")
+		switch name {
+		case "mt":
+			p.selectedMatch, p.contextMatch = value, value
+		case "ms":
+			p.selectedMatch = value
+		case "mc":
+			p.contextMatch = value
+		case "sl":
+			p.selectedLine = value
+		case "cx":
+			p.contextLine = value
+		case "fn":
+			p.filename = value
+		case "ln":
+			p.lineNumber = value
+		case "bn":
+			p.byteOffset = value
+		case "se":
+			p.separator = value
+		case "ne":
+			p.noErase = true
+		}
+	}
+	return p
+}
+
+func cut(s, sep string) (before, after string, found bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+// start and end return the sequences around a part colored with sgr.
+func (p palette) start(sgr string) string {
+	if sgr == "" {
+		return ""
+	}
+	if p.noErase {
+		return "\033[" + sgr + "m"
+	}
+	return "\033[" + sgr + "m\033[K"
+}
+
+func (p palette) end(sgr string) string {
+	if sgr == "" {
+		return ""
+	}
+	if p.noErase {
+		return "\033[m"
+	}
+	return "\033[m\033[K"
+}
+
+func (p palette) paint(dst []byte, sgr, text string) []byte {
+	dst = append(dst, p.start(sgr)...)
+	dst = append(dst, text...)
+	return append(dst, p.end(sgr)...)
+}
+
+// useColors picks the palette to print with, which is empty without colors.
+func (searcher *Searcher) useColors() {
+	switch {
+	case searcher.color == ColorAlways,
+		searcher.color == ColorAuto && isTerminal(searcher.writer):
+		searcher.palette = searcher.colors
+	default:
+		searcher.palette = palette{}
+	}
+}
+
+// isTerminal tells whether writer is a terminal that can show colors.
+func isTerminal(writer io.Writer) bool {
+	file, ok := writer.(*os.File)
+	if !ok || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// printMatch prints a selected line, after a ':', or a context line, after a
+// '-', preceded by a group separator if it doesn't follow the last line
+// printed. With -o, it prints the matches of selected lines only.
+func (searcher *Searcher) printMatch(m Match) error {
+	p := searcher.palette
+	out := searcher.out[:0]
+	if searcher.grouped() && searcher.printed && m.LineNumber != searcher.lastPrinted+1 {
+		out = p.paint(out, p.separator, "--")
+		out = append(out, '\n')
+	}
+	searcher.printed, searcher.lastPrinted = true, m.LineNumber
+	if searcher.replace && !m.Context {
+		m.Line, m.Submatches = searcher.replaceLine([]byte(m.Line))
+	}
+	switch {
+	case searcher.onlyMatching && m.Context:
+	case searcher.onlyMatching:
+		for _, span := range spans(m.Submatches) {
+			out = searcher.appendPrefix(out, m, m.ByteOffset+int64(span.Start))
+			out = p.paint(out, p.selectedMatch, m.Line[span.Start:span.End])
+			out = append(out, '\n')
+		}
+	default:
+		out = searcher.appendPrefix(out, m, m.ByteOffset)
+		out = searcher.appendLine(out, m)
+		out = append(out, '\n')
+	}
+	searcher.out = out
+	_, err := searcher.writer.Write(out)
+	return err
+}
+
+// appendPrefix appends the label, line number and byte offset asked for, each
+// followed by a separator.
+func (searcher *Searcher) appendPrefix(out []byte, m Match, offset int64) []byte {
+	p := searcher.palette
+	sep := ":"
+	if m.Context {
+		sep = "-"
+	}
+	if searcher.filenames {
+		out = p.paint(out, p.filename, m.Path)
+		out = p.paint(out, p.separator, sep)
+	}
+	if searcher.lineNumbers {
+		out = p.paint(out, p.lineNumber, strconv.Itoa(m.LineNumber))
+		out = p.paint(out, p.separator, sep)
+	}
+	if searcher.byteOffsets {
+		out = p.paint(out, p.byteOffset, strconv.FormatInt(offset, 10))
+		out = p.paint(out, p.separator, sep)
+	}
+	return out
+}
+
+// appendLine appends the line with its matches highlighted, the line color
+// being restored after each match.
+func (searcher *Searcher) appendLine(out []byte, m Match) []byte {
+	p := searcher.palette
+	if p == (palette{}) {
+		return append(out, m.Line...)
+	}
+	lineColor, matchColor := p.selectedLine, p.selectedMatch
+	if m.Context {
+		lineColor, matchColor = p.contextLine, p.contextMatch
+	}
+	out = append(out, p.start(lineColor)...)
+	colored := true
+	last := 0
+	for _, span := range spans(m.Submatches) {
+		out = append(out, m.Line[last:span.Start]...)
+		out = p.paint(out, matchColor, m.Line[span.Start:span.End])
+		last = span.End
+		colored = last < len(m.Line)
+		if colored {
+			out = append(out, p.start(lineColor)...)
+		}
+	}
+	out = append(out, m.Line[last:]...)
+	if colored {
+		out = append(out, p.end(lineColor)...)
+	}
+	return out
+}
+
+// spans merges overlapping ranges, and drops empty ones, so that each part of
+// a line is highlighted once.
+func spans(ranges []Range) []Range {
+	merged := make([]Range, 0, len(ranges))
+	for _, r := range ranges {
+		if r.Start < r.End {
+			merged = append(merged, r)
+		}
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].Start < merged[j].Start })
+	n := 0
+	for _, r := range merged {
+		if n > 0 && r.Start < merged[n-1].End {
+			if r.End > merged[n-1].End {
+				merged[n-1].End = r.End
+			}
+			continue
+		}
+		merged[n] = r
+		n++
+	}
+	return merged[:n]
+}
@@ -0,0 +1,327 @@
+package grep
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// WithBooleanQuery interprets patterns as queries combining patterns with
+// AND, OR and NOT, such as `error AND (db OR cache) AND NOT timeout`. NOT
+// binds tighter than AND, and AND tighter than OR. Patterns containing spaces,
+// parentheses or quotes, or spelled like an operator, are written in double
+// quotes, with \" and \\ as escapes. Each line of a query is an alternative,
+// as each line of a pattern file is for GNU grep, and blank lines are skipped.
+// Patterns are matched according to the other matching options.
+func WithBooleanQuery() option {
+	return func(searcher *Searcher) {
+		searcher.query = true
+	}
+}
+
+// QueryError is a syntax error in a query, at a line and byte column counted
+// from 1.
+type QueryError struct {
+	Line, Column int
+	Msg          string
+}
+
+func (err *QueryError) Error() string {
+	return fmt.Sprintf("%d:%d: %s", err.Line, err.Column, err.Msg)
+}
+
+// matcher selects lines, and finds the ranges to highlight in them.
+type matcher interface {
+	match(line []byte) bool
+	// ranges appends the ranges of a selected line matched by its patterns
+	ranges(line []byte, dst []Range) []Range
+}
+
+type regexpMatcher struct {
+	re *regexp.Regexp
+	// words only takes the matches of re that are whole words, with -w
+	words *wordFinder
+}
+
+func (m regexpMatcher) match(line []byte) bool {
+	if m.words != nil {
+		return m.words.first.Match(line)
+	}
+	return m.re.Match(line)
+}
+
+func (m regexpMatcher) ranges(line []byte, dst []Range) []Range {
+	for _, loc := range m.findAll(line) {
+		dst = append(dst, Range{loc[0], loc[1]})
+	}
+	return dst
+}
+
+// findAll returns the submatch indexes of the successive matches in line.
+func (m regexpMatcher) findAll(line []byte) [][]int {
+	if m.words != nil {
+		return m.words.findAll(line)
+	}
+	return m.re.FindAllSubmatchIndex(line, -1)
+}
+
+type andMatcher struct {
+	left, right matcher
+}
+
+func (m andMatcher) match(line []byte) bool {
+	return m.left.match(line) && m.right.match(line)
+}
+
+func (m andMatcher) ranges(line []byte, dst []Range) []Range {
+	return m.right.ranges(line, m.left.ranges(line, dst))
+}
+
+type orMatcher struct {
+	left, right matcher
+}
+
+func (m orMatcher) match(line []byte) bool {
+	return m.left.match(line) || m.right.match(line)
+}
+
+// ranges only highlights the alternatives that match.
+func (m orMatcher) ranges(line []byte, dst []Range) []Range {
+	if m.left.match(line) {
+		dst = m.left.ranges(line, dst)
+	}
+	if m.right.match(line) {
+		dst = m.right.ranges(line, dst)
+	}
+	return dst
+}
+
+type notMatcher struct {
+	operand matcher
+}
+
+func (m notMatcher) match(line []byte) bool {
+	return !m.operand.match(line)
+}
+
+// ranges highlights nothing, as nothing a negation matches is in the line.
+func (m notMatcher) ranges(line []byte, dst []Range) []Range {
+	return dst
+}
+
+// queryMatcher sorts the ranges of its query by where they start. Ranges of
+// different patterns may overlap.
+type queryMatcher struct {
+	matcher
+}
+
+func (m queryMatcher) ranges(line []byte, dst []Range) []Range {
+	dst = m.matcher.ranges(line, dst)
+	sort.SliceStable(dst, func(i, j int) bool { return dst[i].Start < dst[j].Start })
+	return dst
+}
+
+type tokenKind int
+
+const (
+	tokenEnd tokenKind = iota
+	tokenPattern
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenOpen
+	tokenClose
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	// column is the byte offset of the token in its line
+	column int
+}
+
+// queryParser parses one line of a query by recursive descent.
+type queryParser struct {
+	searcher *Searcher
+	line     int
+	tokens   []token
+	next     int
+}
+
+// parseQuery compiles a query, patterns being compiled by the searcher.
+func (searcher *Searcher) parseQuery(text string) (matcher, error) {
+	var query matcher
+	for i, line := range strings.Split(text, "\n") {
+		tokens, err := lexQuery(strings.TrimSuffix(line, "\r"), i+1)
+		if err != nil {
+			return nil, err
+		}
+		if len(tokens) == 1 {
+			continue
+		}
+		parser := &queryParser{searcher: searcher, line: i + 1, tokens: tokens}
+		alternative, err := parser.parse()
+		if err != nil {
+			return nil, err
+		}
+		if query == nil {
+			query = alternative
+		} else {
+			query = orMatcher{query, alternative}
+		}
+	}
+	if query == nil {
+		return nil, &QueryError{Line: 1, Column: 1, Msg: "empty query"}
+	}
+	return queryMatcher{query}, nil
+}
+
+// lexQuery splits a line into tokens, ending with a tokenEnd.
+func lexQuery(line string, number int) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(line); {
+		switch c := line[i]; {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{tokenOpen, "(", i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{tokenClose, ")", i})
+			i++
+		case c == '"':
+			text, end, ok := unquote(line, i)
+			if !ok {
+				return nil, &QueryError{Line: number, Column: i + 1, Msg: "unterminated quoted pattern"}
+			}
+			tokens = append(tokens, token{tokenPattern, text, i})
+			i = end
+		default:
+			end := i
+			for end < len(line) && !strings.ContainsRune(" \t()\"", rune(line[end])) {
+				end++
+			}
+			word := line[i:end]
+			kind := tokenPattern
+			switch word {
+			case "AND":
+				kind = tokenAnd
+			case "OR":
+				kind = tokenOr
+			case "NOT":
+				kind = tokenNot
+			}
+			tokens = append(tokens, token{kind, word, i})
+			i = end
+		}
+	}
+	return append(tokens, token{tokenEnd, "", len(line)}), nil
+}
+
+// unquote reads the quoted pattern starting at line[start], returning it and
+// the offset past its closing quote.
+func unquote(line string, start int) (string, int, bool) {
+	var text strings.Builder
+	for i := start + 1; i < len(line); i++ {
+		switch c := line[i]; {
+		case c == '"':
+			return text.String(), i + 1, true
+		case c == '\\' && i+1 < len(line) && (line[i+1] == '"' || line[i+1] == '\\'):
+			i++
+			text.WriteByte(line[i])
+		default:
+			text.WriteByte(c)
+		}
+	}
+	return "", 0, false
+}
+
+func (parser *queryParser) parse() (matcher, error) {
+	query, err := parser.or()
+	if err != nil {
+		return nil, err
+	}
+	switch t := parser.peek(); t.kind {
+	case tokenEnd:
+		return query, nil
+	case tokenClose:
+		return nil, parser.errorAt(t, "unexpected )")
+	default:
+		return nil, parser.errorAt(t, "expected AND or OR before "+describeToken(t))
+	}
+}
+
+func (parser *queryParser) or() (matcher, error) {
+	left, err := parser.and()
+	for err == nil && parser.peek().kind == tokenOr {
+		parser.next++
+		var right matcher
+		right, err = parser.and()
+		left = orMatcher{left, right}
+	}
+	return left, err
+}
+
+func (parser *queryParser) and() (matcher, error) {
+	left, err := parser.unary()
+	for err == nil && parser.peek().kind == tokenAnd {
+		parser.next++
+		var right matcher
+		right, err = parser.unary()
+		left = andMatcher{left, right}
+	}
+	return left, err
+}
+
+func (parser *queryParser) unary() (matcher, error) {
+	if parser.peek().kind == tokenNot {
+		parser.next++
+		operand, err := parser.unary()
+		return notMatcher{operand}, err
+	}
+	return parser.primary()
+}
+
+func (parser *queryParser) primary() (matcher, error) {
+	t := parser.peek()
+	switch t.kind {
+	case tokenPattern:
+		parser.next++
+		m, err := parser.searcher.compileRegexp(t.text)
+		if err != nil {
+			return nil, parser.errorAt(t, err.Error())
+		}
+		return m, nil
+	case tokenOpen:
+		parser.next++
+		query, err := parser.or()
+		if err != nil {
+			return nil, err
+		}
+		if closing := parser.peek(); closing.kind != tokenClose {
+			return nil, parser.errorAt(closing, "expected ) to close ( at column "+fmt.Sprint(t.column+1)+", found "+describeToken(closing))
+		}
+		parser.next++
+		return query, nil
+	}
+	return nil, parser.errorAt(t, "expected a pattern, ( or NOT, found "+describeToken(t))
+}
+
+func (parser *queryParser) peek() token {
+	return parser.tokens[parser.next]
+}
+
+func (parser *queryParser) errorAt(t token, msg string) error {
+	return &QueryError{Line: parser.line, Column: t.column + 1, Msg: msg}
+}
+
+func describeToken(t token) string {
+	switch t.kind {
+	case tokenEnd:
+		return "end of line"
+	case tokenPattern:
+		return fmt.Sprintf("pattern %q", t.text)
+	}
+	return t.text
+}
@@ -0,0 +1,132 @@
+package grep_test
+
+import (
+	"bytes"
+	"errors"
+	"grep"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBooleanQuery(t *testing.T) {
+	t.Parallel()
+	input := `error: db timeout
+error: db down
+error: cache miss
+warning: cache timeout
+error AND db
+`
+	tests := []struct {
+		name     string
+		query    string
+		searcher *grep.Searcher
+		want     string
+	}{
+		{"and", "error AND db", grep.NewSearcher(), "error: db timeout\nerror: db down\nerror AND db\n"},
+		{"or", "down OR miss", grep.NewSearcher(), "error: db down\nerror: cache miss\n"},
+		{"not", "NOT error", grep.NewSearcher(), "warning: cache timeout\n"},
+		{"precedence", "warning OR error AND NOT timeout AND NOT \"AND\"", grep.NewSearcher(), "error: db down\nerror: cache miss\nwarning: cache timeout\n"},
+		{"parentheses", "error AND (db OR cache) AND NOT timeout", grep.NewSearcher(), "error: db down\nerror: cache miss\nerror AND db\n"},
+		{"double negation", "NOT NOT warning", grep.NewSearcher(), "warning: cache timeout\n"},
+		{"quoted", `"error AND" OR "cache \"miss\""`, grep.NewSearcher(), "error AND db\n"},
+		{"lines are alternatives", "down\n\nwarning AND db\r\nmiss", grep.NewSearcher(), "error: db down\nerror: cache miss\n"},
+		{"matching options", "ERROR AND (d.*n OR MISS)", grep.NewSearcher(grep.IgnoreCase(), grep.WithExtendedRegexp()), "error: db down\nerror: cache miss\n"},
+		{"whole words", "db AND NOT time", grep.NewSearcher(grep.WordRegexp()), "error: db timeout\nerror: db down\nerror AND db\n"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			output := &bytes.Buffer{}
+			searcher := tt.searcher.
+				WithReader(strings.NewReader(input)).
+				WithWriter(output)
+			grep.WithBooleanQuery()(searcher)
+			if err := searcher.Search(tt.query); err != nil {
+				t.Fatal(err)
+			}
+			if got := output.String(); got != tt.want {
+				t.Errorf("want: %#v, got: %#v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestBooleanQuerySubmatches(t *testing.T) {
+	t.Parallel()
+	var got []grep.Range
+	searcher := grep.NewSearcher(
+		grep.WithReader(strings.NewReader("pineapple pie\n")),
+		grep.WithBooleanQuery(),
+		grep.OnMatch(func(m grep.Match) error {
+			got = m.Submatches
+			return nil
+		}),
+	)
+	if err := searcher.Search("(apple OR pine OR kiwi) AND NOT cherry AND pie"); err != nil {
+		t.Fatal(err)
+	}
+	want := []grep.Range{{0, 4}, {4, 9}, {10, 13}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want: %v, got: %v", want, got)
+	}
+}
+
+func TestBooleanQueryOverlappingHighlights(t *testing.T) {
+	t.Parallel()
+	output := &bytes.Buffer{}
+	searcher := grep.NewSearcher(
+		grep.WithReader(strings.NewReader("pineapple\n")),
+		grep.WithWriter(output),
+		grep.WithBooleanQuery(),
+		grep.WithColor(grep.ColorAlways),
+		grep.WithGrepColors("ms=1:ne"),
+	)
+	if err := searcher.Search("neap AND apple AND pin"); err != nil {
+		t.Fatal(err)
+	}
+	want := "\033[1mpineapple\033[m\n"
+	if got := output.String(); got != want {
+		t.Errorf("want: %q, got: %q", want, got)
+	}
+}
+
+func TestBooleanQueryErrors(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		query string
+		want  grep.QueryError
+	}{
+		{"", grep.QueryError{Line: 1, Column: 1, Msg: "empty query"}},
+		{"a AND", grep.QueryError{Line: 1, Column: 6, Msg: "expected a pattern, ( or NOT, found end of line"}},
+		{"a b", grep.QueryError{Line: 1, Column: 3, Msg: `expected AND or OR before pattern "b"`}},
+		{"a OR (b AND c", grep.QueryError{Line: 1, Column: 14, Msg: "expected ) to close ( at column 6, found end of line"}},
+		{"a)", grep.QueryError{Line: 1, Column: 2, Msg: "unexpected )"}},
+		{"ok\n  OR b", grep.QueryError{Line: 2, Column: 3, Msg: "expected a pattern, ( or NOT, found OR"}},
+		{`a AND "b`, grep.QueryError{Line: 1, Column: 7, Msg: "unterminated quoted pattern"}},
+	}
+	for _, tt := range tests {
+		err := grep.NewSearcher(
+			grep.WithReader(strings.NewReader("")),
+			grep.WithBooleanQuery(),
+		).Search(tt.query)
+		var got *grep.QueryError
+		if !errors.As(err, &got) || *got != tt.want {
+			t.Errorf("%q: want %v, got %v", tt.query, &tt.want, err)
+		}
+	}
+}
+
+func TestBooleanQueryRegexpErrorPosition(t *testing.T) {
+	t.Parallel()
+	err := grep.NewSearcher(
+		grep.WithReader(strings.NewReader("")),
+		grep.WithBooleanQuery(),
+		grep.WithExtendedRegexp(),
+	).Search(`a OR "b["`)
+	var got *grep.QueryError
+	if !errors.As(err, &got) || got.Line != 1 || got.Column != 6 || !strings.Contains(got.Msg, "missing closing ]") {
+		t.Errorf("want an error at 1:6 about the regexp, got %v", err)
+	}
+}
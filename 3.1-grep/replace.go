@@ -0,0 +1,224 @@
+package grep
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// diffContext is how many unchanged lines a diff shows around changes.
+const diffContext = 3
+
+var (
+	errReplaceQuery = errors.New("can't replace the matches of a boolean query")
+	errNotOnDisk    = errors.New("not a file on disk")
+)
+
+// WithReplacement prints selected lines with each match replaced by template,
+// in which $1 or ${1} stands for the text of the first capture group, ${name}
+// for that of a named one, and $$ for a dollar, as in regexp.Expand
+// (--replace). Matches given to OnMatch handlers are left as they are.
+func WithReplacement(template string) option {
+	return func(searcher *Searcher) {
+		searcher.replace = true
+		searcher.replacement = template
+	}
+}
+
+// DiffReplacements prints a unified diff of the replacements in each input,
+// instead of the lines (--diff).
+func DiffReplacements() option {
+	return func(searcher *Searcher) {
+		searcher.mode = printDiff
+	}
+}
+
+// ReplaceInPlace has SearchFiles rewrite the files with selected lines, with
+// their matches replaced, as well as printing what the output mode says
+// (--in-place). A file is written to a temporary file next to it, with the
+// same permissions, which is then renamed over it, so that it is never left
+// half written. Inputs that aren't files on disk are reported as errors.
+func ReplaceInPlace() option {
+	return func(searcher *Searcher) {
+		searcher.inPlace = true
+	}
+}
+
+// replaceLine returns line with its matches replaced, and the ranges of the
+// replacements in it.
+func (searcher *Searcher) replaceLine(line []byte) (string, []Range) {
+	// compile refuses to replace with anything but a regular expression
+	m := searcher.compiled.(regexpMatcher)
+	template := []byte(searcher.replacement)
+	var out []byte
+	var ranges []Range
+	last := 0
+	for _, loc := range m.findAll(line) {
+		out = append(out, line[last:loc[0]]...)
+		start := len(out)
+		out = m.re.Expand(out, template, line, loc)
+		ranges = append(ranges, Range{start, len(out)})
+		last = loc[1]
+	}
+	out = append(out, line[last:]...)
+	return string(out), ranges
+}
+
+// rewrite replaces the matches of the selected lines of the file at path, up
+// to the maximum count.
+func (searcher *Searcher) rewrite(path string) error {
+	// renaming over a symbolic link would replace the link
+	path, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	out := make([]byte, 0, len(data))
+	lines := newLineScanner(bytes.NewReader(data))
+	selected := 0
+	for lines.scan() {
+		line := lines.current
+		if (searcher.maxCount < 0 || selected < searcher.maxCount) && searcher.compiled.match(line.text) {
+			selected++
+			replaced, _ := searcher.replaceLine(line.text)
+			out = append(out, replaced...)
+		} else {
+			out = append(out, line.text...)
+		}
+		if end := line.offset + int64(len(line.text)); end < int64(len(data)) {
+			out = append(out, '\n')
+		}
+	}
+	if bytes.Equal(out, data) {
+		return nil
+	}
+	return writeFileAtomically(path, out, info.Mode().Perm())
+}
+
+func writeFileAtomically(path string, data []byte, perm fs.FileMode) error {
+	temp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	_, err = temp.Write(data)
+	if err == nil {
+		err = temp.Chmod(perm)
+	}
+	if err == nil {
+		err = temp.Sync()
+	}
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(temp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(temp.Name())
+	}
+	return err
+}
+
+// printDiff prints the lines of an input kept by deliver, selected lines and
+// the context around them, as a unified diff of their replacements.
+func (searcher *Searcher) printDiff(label string) error {
+	lines := searcher.diffLines
+	searcher.diffLines = lines[:0]
+	out := searcher.out[:0]
+	headed := false
+	// delta is how many more lines the new file has before the current hunk
+	delta := 0
+	for start := 0; start < len(lines); {
+		end := start + 1
+		for end < len(lines) && lines[end].LineNumber == lines[end-1].LineNumber+1 {
+			end++
+		}
+		group := lines[start:end]
+		start = end
+		replaced := make([]string, len(group))
+		var changed []int
+		for i, m := range group {
+			replaced[i] = m.Line
+			if !m.Context {
+				replaced[i], _ = searcher.replaceLine([]byte(m.Line))
+			}
+			if replaced[i] != m.Line {
+				changed = append(changed, i)
+			}
+		}
+		for len(changed) > 0 {
+			// a hunk takes in the changes that its context reaches
+			n := 1
+			for n < len(changed) && changed[n]-changed[n-1] <= 2*diffContext+1 {
+				n++
+			}
+			first, last := changed[0]-diffContext, changed[n-1]+diffContext
+			changed = changed[n:]
+			if first < 0 {
+				first = 0
+			}
+			if last >= len(group) {
+				last = len(group) - 1
+			}
+			if !headed {
+				headed = true
+				out = append(out, "--- "+label+"\n+++ "+label+"\n"...)
+			}
+			var body []byte
+			oldCount, newCount := 0, 0
+			for i := first; i <= last; {
+				if replaced[i] == group[i].Line {
+					body = append(append(append(body, ' '), group[i].Line...), '\n')
+					oldCount++
+					newCount++
+					i++
+					continue
+				}
+				j := i
+				for j <= last && replaced[j] != group[j].Line {
+					body = append(append(append(body, '-'), group[j].Line...), '\n')
+					oldCount++
+					j++
+				}
+				for ; i < j; i++ {
+					for _, line := range bytes.Split([]byte(replaced[i]), []byte{'\n'}) {
+						body = append(append(append(body, '+'), line...), '\n')
+						newCount++
+					}
+				}
+			}
+			oldStart := group[first].LineNumber
+			out = append(out, "@@ -"...)
+			out = appendRange(out, oldStart, oldCount)
+			out = append(out, " +"...)
+			out = appendRange(out, oldStart+delta, newCount)
+			out = append(out, " @@\n"...)
+			out = append(out, body...)
+			delta += newCount - oldCount
+		}
+	}
+	searcher.out = out
+	_, err := searcher.writer.Write(out)
+	return err
+}
+
+// appendRange appends a range of a hunk header, leaving out a count of one
+// as diff does.
+func appendRange(out []byte, start, count int) []byte {
+	out = strconv.AppendInt(out, int64(start), 10)
+	if count != 1 {
+		out = append(out, ',')
+		out = strconv.AppendInt(out, int64(count), 10)
+	}
+	return out
+}
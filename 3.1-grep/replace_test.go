@@ -0,0 +1,188 @@
+package grep_test
+
+import (
+	"bytes"
+	"errors"
+	"grep"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestReplacement(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		pattern  string
+		searcher *grep.Searcher
+		want     string
+	}{
+		{"fixed string", "a.b", grep.NewSearcher(grep.WithReplacement("[$0]")), "x [a.b] [a.b]\n"},
+		{"capture groups", `(\w)\.(\w)`, grep.NewSearcher(grep.WithExtendedRegexp(), grep.WithReplacement("${2}-$1")), "x b-a b-a\ny-x\n"},
+		{"named groups", `(?P<first>\w)\.\w`, grep.NewSearcher(grep.WithExtendedRegexp(), grep.WithReplacement("$first$$")), "x a$ a$\nx$\n"},
+		{"deleting matches", "a.b ", grep.NewSearcher(grep.WithReplacement("")), "x a.b\n"},
+		{"context left alone", "x.y", grep.NewSearcher(grep.WithReplacement("z"), grep.WithBeforeContext(1)), "x a.b a.b\nz\n"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			output := &bytes.Buffer{}
+			searcher := tt.searcher.
+				WithReader(strings.NewReader("x a.b a.b\nx.y\n")).
+				WithWriter(output)
+			if err := searcher.Search(tt.pattern); err != nil {
+				t.Fatal(err)
+			}
+			if got := output.String(); got != tt.want {
+				t.Errorf("want: %#v, got: %#v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDiffReplacements(t *testing.T) {
+	t.Parallel()
+	var input strings.Builder
+	for _, line := range []string{"old", "1", "2", "3", "4", "5", "6", "7", "8", "old", "9", "old"} {
+		input.WriteString(line + "\n")
+	}
+	output := &bytes.Buffer{}
+	searcher := grep.NewSearcher(
+		grep.WithReader(strings.NewReader(input.String())),
+		grep.WithWriter(output),
+		grep.WithLabel("config"),
+		grep.WithReplacement("new\nline"),
+		grep.DiffReplacements(),
+	)
+	if err := searcher.Search("old"); err != nil {
+		t.Fatal(err)
+	}
+	want := `--- config
++++ config
+@@ -1,4 +1,5 @@
+-old
++new
++line
+ 1
+ 2
+ 3
+@@ -7,6 +8,8 @@
+ 6
+ 7
+ 8
+-old
++new
++line
+ 9
+-old
++new
++line
+`
+	if got := output.String(); got != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestDiffReplacementsWithoutChanges(t *testing.T) {
+	t.Parallel()
+	output := &bytes.Buffer{}
+	searcher := grep.NewSearcher(
+		grep.WithReader(strings.NewReader("same\n")),
+		grep.WithWriter(output),
+		grep.WithReplacement("$0"),
+		grep.DiffReplacements(),
+	)
+	if err := searcher.Search("same"); err != nil {
+		t.Fatal(err)
+	}
+	if output.Len() != 0 {
+		t.Errorf("want no diff, got %q", output.String())
+	}
+}
+
+func TestReplaceInPlace(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	config := filepath.Join(dir, "app.conf")
+	if err := os.WriteFile(config, []byte("host = old\nport = 1\nbackup = old\nlast = old"), 0o640); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("app.conf", filepath.Join(dir, "link.conf")); err != nil {
+		t.Fatal(err)
+	}
+	untouched := filepath.Join(dir, "other.conf")
+	if err := os.WriteFile(untouched, []byte("nothing\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	output := &bytes.Buffer{}
+	searcher := grep.NewSearcher(
+		grep.WithWriter(output),
+		grep.WithReplacement("new"),
+		grep.ReplaceInPlace(),
+		grep.WithMaxCount(3),
+		grep.Quiet(),
+	)
+	if err := searcher.SearchFiles("old", filepath.Join(dir, "link.conf"), untouched); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "host = new\nport = 1\nbackup = new\nlast = new"; string(data) != want {
+		t.Errorf("want %q, got %q", want, data)
+	}
+	info, err := os.Lstat(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode() != 0o640 {
+		t.Errorf("want mode 0640, got %v", info.Mode())
+	}
+	if link, err := os.Lstat(filepath.Join(dir, "link.conf")); err != nil || link.Mode()&fs.ModeSymlink == 0 {
+		t.Errorf("want link.conf left a symbolic link, got %v, %v", link, err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Errorf("want no temporary file left, got %v", entries)
+	}
+	if output.Len() != 0 {
+		t.Errorf("want no output, got %q", output.String())
+	}
+}
+
+func TestReplaceInPlaceNeedsFiles(t *testing.T) {
+	t.Parallel()
+	searcher := grep.NewSearcher(
+		grep.WithReader(strings.NewReader("old\n")),
+		grep.WithReplacement("new"),
+		grep.ReplaceInPlace(),
+	)
+	var pathErr *fs.PathError
+	if err := searcher.Search("old"); !errors.As(err, &pathErr) || pathErr.Path != "(standard input)" {
+		t.Errorf("want an error about the standard input, got %v", err)
+	}
+	fsys := fstest.MapFS{"a.txt": {Data: []byte("old\n")}}
+	if err := searcher.SearchFS(fsys, "old"); !errors.As(err, &pathErr) || pathErr.Path != "a.txt" {
+		t.Errorf("want an error about a.txt, got %v", err)
+	}
+}
+
+func TestReplaceQuery(t *testing.T) {
+	t.Parallel()
+	searcher := grep.NewSearcher(
+		grep.WithReader(strings.NewReader("old\n")),
+		grep.WithBooleanQuery(),
+		grep.WithReplacement("new"),
+	)
+	if err := searcher.Search("old OR older"); err == nil {
+		t.Error("want an error replacing with a query, got nil")
+	}
+}
@@ -0,0 +1,149 @@
+package grep
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+var errStop = errors.New("stop walking")
+
+// WithGlob only searches files matching glob, or, for a glob starting with !,
+// skips files and directories matching it. Globs use gitignore syntax, and
+// when several match a path the last one wins.
+func WithGlob(glob string) option {
+	return func(searcher *Searcher) {
+		if p, ok := parseIgnorePattern(glob); ok {
+			searcher.globs = append(searcher.globs, p)
+		}
+	}
+}
+
+// IncludeHidden also searches files and directories whose name starts with a
+// dot.
+func IncludeHidden() option {
+	return func(searcher *Searcher) {
+		searcher.hidden = true
+	}
+}
+
+// IncludeBinary also searches the binary files found by walking directories,
+// which are otherwise skipped, handling them as WithBinaryFiles says.
+func IncludeBinary() option {
+	return func(searcher *Searcher) {
+		searcher.binary = true
+	}
+}
+
+// WithErrorHandler has SearchFiles and SearchFS report errors about single files to handle and
+// carry on with the other files.
+func WithErrorHandler(handle func(error)) option {
+	return func(searcher *Searcher) {
+		searcher.handleError = handle
+	}
+}
+
+// SearchFS searches the files under the roots in fsys, or under "." if there
+// are none, as grep -r does. It skips hidden files, binary files, and files
+// ignored by .gitignore and .ignore files, and labels lines with the path of
+// their file. Errors are handled as by SearchFiles.
+func (searcher *Searcher) SearchFS(fsys fs.FS, what string, roots ...string) error {
+	if len(roots) == 0 {
+		roots = []string{"."}
+	}
+	if !searcher.filenamesSet {
+		searcher.filenames = true
+	}
+	return searcher.run(what, func(send func(input) bool) {
+		for _, root := range roots {
+			if !searcher.walkInputs(fsys, root, "", send) {
+				return
+			}
+		}
+	})
+}
+
+// walkInputs sends the files under root to send, labelled with their path in
+// fsys after prefix and a slash if prefix isn't empty. It returns false once
+// send does.
+func (searcher *Searcher) walkInputs(fsys fs.FS, root, prefix string, send func(input) bool) bool {
+	err := searcher.walk(fsys, root, func(name string, err error) error {
+		in := input{label: joinLabel(prefix, name), found: true, err: relabel(err, prefix)}
+		in.open = func() (io.ReadCloser, error) {
+			file, err := fsys.Open(name)
+			return file, relabel(err, prefix)
+		}
+		if !send(in) {
+			return errStop
+		}
+		return nil
+	})
+	return err != errStop
+}
+
+func joinLabel(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return strings.TrimSuffix(prefix, "/") + "/" + name
+}
+
+// walk calls fn with the regular files under root that aren't hidden, ignored
+// or filtered out by globs, and with the errors met on the way. root itself is
+// never filtered out.
+func (searcher *Searcher) walk(fsys fs.FS, root string, fn func(name string, err error) error) error {
+	var stack ignoreStack
+	return fs.WalkDir(fsys, root, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return fn(name, err)
+		}
+		stack = stack.enter(name)
+		isDir := entry.IsDir()
+		if name != root && searcher.skip(stack, name, isDir) {
+			if isDir {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if isDir {
+			stack = append(stack, readIgnoreRules(fsys, name))
+			return nil
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		return fn(name, nil)
+	})
+}
+
+func (searcher *Searcher) skip(stack ignoreStack, name string, isDir bool) bool {
+	if !searcher.hidden && strings.HasPrefix(path.Base(name), ".") {
+		return true
+	}
+	if stack.ignored(name, isDir) {
+		return true
+	}
+	return !searcher.globbed(name, isDir)
+}
+
+// globbed reports whether the globs let name through. The last glob matching
+// decides; a file that no glob matches is only searched if all globs
+// exclude, and a directory only if no excluding glob matches it.
+func (searcher *Searcher) globbed(name string, isDir bool) bool {
+	for i := len(searcher.globs) - 1; i >= 0; i-- {
+		if glob := searcher.globs[i]; glob.match(name, isDir) {
+			return !glob.negate
+		}
+	}
+	if isDir {
+		return true
+	}
+	for _, glob := range searcher.globs {
+		if !glob.negate {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,138 @@
+package grep_test
+
+import (
+	"bytes"
+	"grep"
+	"testing"
+	"testing/fstest"
+)
+
+var testFS = fstest.MapFS{
+	"main.go":                   {Data: []byte("needle in main\n")},
+	"README.md":                 {Data: []byte("needle in readme\n")},
+	".hidden":                   {Data: []byte("needle in hidden file\n")},
+	".config/settings":          {Data: []byte("needle in hidden dir\n")},
+	"image.png":                 {Data: []byte("needle\x00in binary\n")},
+	".gitignore":                {Data: []byte("# build output\n*.log\n!keep.log\nbuild/\n/root.txt\ndocs/*.md\n\\#hash\ntrailing \n")},
+	"app.log":                   {Data: []byte("needle in log\n")},
+	"keep.log":                  {Data: []byte("needle in kept log\n")},
+	"root.txt":                  {Data: []byte("needle in anchored\n")},
+	"#hash":                     {Data: []byte("needle in hash\n")},
+	"trailing":                  {Data: []byte("needle in trailing\n")},
+	"build/out.go":              {Data: []byte("needle in build dir\n")},
+	"docs/guide.md":             {Data: []byte("needle in docs\n")},
+	"docs/deep/guide.md":        {Data: []byte("needle in deep docs\n")},
+	"src/root.txt":              {Data: []byte("needle in unanchored\n")},
+	"src/build":                 {Data: []byte("needle in build file\n")},
+	"src/lib/lib.go":            {Data: []byte("needle in lib\nno match\n")},
+	"src/lib/gen.go":            {Data: []byte("needle in generated\n")},
+	"src/lib/.ignore":           {Data: []byte("gen.go\n!*.log\n")},
+	"src/lib/debug.log":         {Data: []byte("needle in re-included log\n")},
+	"vendor/dep/dep.go":         {Data: []byte("needle in vendor\n")},
+	"vendor/dep/dep_test.go":    {Data: []byte("needle in vendor test\n")},
+	"vendor/a/b/c/nested.go":    {Data: []byte("needle in nested vendor\n")},
+	"vendor/.gitignore":         {Data: []byte("**/c/\n")},
+	"empty.txt":                 {Data: []byte{}},
+	"src/lib/subdir/sub.go":     {Data: []byte("needle in subdir\n")},
+	"src/lib/subdir/.gitignore": {Data: []byte("!gen.go\n")},
+	"src/lib/subdir/gen.go":     {Data: []byte("needle in re-included gen\n")},
+}
+
+func searchFS(t *testing.T, searcher *grep.Searcher, roots ...string) string {
+	t.Helper()
+	output := &bytes.Buffer{}
+	if err := searcher.WithWriter(output).SearchFS(testFS, "needle", roots...); err != nil {
+		t.Fatal(err)
+	}
+	return output.String()
+}
+
+func TestSearchFSHonoursIgnoreFiles(t *testing.T) {
+	t.Parallel()
+	want := `README.md:needle in readme
+docs/deep/guide.md:needle in deep docs
+keep.log:needle in kept log
+main.go:needle in main
+src/build:needle in build file
+src/lib/debug.log:needle in re-included log
+src/lib/lib.go:needle in lib
+src/lib/subdir/gen.go:needle in re-included gen
+src/lib/subdir/sub.go:needle in subdir
+src/root.txt:needle in unanchored
+vendor/dep/dep.go:needle in vendor
+vendor/dep/dep_test.go:needle in vendor test
+`
+	if got := searchFS(t, grep.NewSearcher()); got != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestSearchFSRoots(t *testing.T) {
+	t.Parallel()
+	want := `src/lib/debug.log:needle in re-included log
+src/lib/lib.go:needle in lib
+src/lib/subdir/gen.go:needle in re-included gen
+src/lib/subdir/sub.go:needle in subdir
+.hidden:needle in hidden file
+`
+	// the rules of ignore files above a root don't apply, and roots are
+	// searched even if hidden
+	if got := searchFS(t, grep.NewSearcher(), "src/lib", ".hidden"); got != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestSearchFSHiddenAndBinary(t *testing.T) {
+	t.Parallel()
+	searcher := grep.NewSearcher(
+		grep.IncludeHidden(),
+		grep.IncludeBinary(),
+		grep.WithGlob("!src/"),
+		grep.WithGlob("!vendor/"),
+		grep.WithGlob("!docs/"),
+		grep.FilesWithMatches(),
+	)
+	want := `.config/settings
+.hidden
+README.md
+image.png
+keep.log
+main.go
+`
+	if got := searchFS(t, searcher); got != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestSearchFSGlobs(t *testing.T) {
+	t.Parallel()
+	withGlobs := func(globs ...string) *grep.Searcher {
+		searcher := grep.NewSearcher(grep.FilesWithMatches())
+		for _, glob := range globs {
+			// options are functions applied to the searcher
+			grep.WithGlob(glob)(searcher)
+		}
+		return searcher
+	}
+	tests := []struct {
+		name     string
+		searcher *grep.Searcher
+		want     string
+	}{
+		{"include", withGlobs("*.go"), "main.go\nsrc/lib/lib.go\nsrc/lib/subdir/gen.go\nsrc/lib/subdir/sub.go\nvendor/dep/dep.go\nvendor/dep/dep_test.go\n"},
+		{"exclude", withGlobs("!*.go", "!*.log", "!*.md"), "src/build\nsrc/root.txt\n"},
+		{"last glob wins", withGlobs("*.go", "!*_test.go", "!src/", "*dep_test.go"), "main.go\nvendor/dep/dep.go\nvendor/dep/dep_test.go\n"},
+		{"anchored", withGlobs("src/*"), "src/build\nsrc/root.txt\n"},
+		{"double star", withGlobs("src/**/*.go"), "src/lib/lib.go\nsrc/lib/subdir/gen.go\nsrc/lib/subdir/sub.go\n"},
+		{"character class", withGlobs("[mr]*"), "main.go\nsrc/root.txt\n"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := searchFS(t, tt.searcher); got != tt.want {
+				t.Errorf("want:\n%s\ngot:\n%s", tt.want, got)
+			}
+		})
+	}
+}
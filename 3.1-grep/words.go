@@ -0,0 +1,66 @@
+package grep
+
+import (
+	"regexp"
+	"unicode/utf8"
+)
+
+// nonWord matches a rune that isn't a word constituent: a letter, a digit or
+// an underscore.
+const nonWord = `[^\pL\p{Nd}_]`
+
+// wordFinder finds the matches of a regular expression that are whole words
+// as GNU grep -w has them: neither preceded nor followed by a word
+// constituent. RE2's \b only knows ASCII, so the runes around a match are
+// matched instead, which keeps the context of the assertions in the pattern.
+type wordFinder struct {
+	// first finds the first whole word match in a line
+	first *regexp.Regexp
+	// next finds one after the rune it starts at
+	next *regexp.Regexp
+}
+
+func newWordFinder(re *regexp.Regexp) (*wordFinder, error) {
+	expr := `(` + re.String() + `)(?:` + nonWord + `|$)`
+	first, err := regexp.Compile(`(?:^|` + nonWord + `)` + expr)
+	if err != nil {
+		return nil, err
+	}
+	next, err := regexp.Compile(nonWord + expr)
+	if err != nil {
+		return nil, err
+	}
+	return &wordFinder{first, next}, nil
+}
+
+// findAll returns the submatch indexes of the successive whole word matches
+// in line, numbered as in the wrapped regular expression.
+func (finder *wordFinder) findAll(line []byte) [][]int {
+	var all [][]int
+	loc := finder.first.FindSubmatchIndex(line)
+	for loc != nil {
+		// the group of the wrapped expression stands for the whole match
+		loc = loc[2:]
+		all = append(all, loc)
+		end := loc[1]
+		if loc[0] == end {
+			if end == len(line) {
+				break
+			}
+			_, size := utf8.DecodeRune(line[end:])
+			end += size
+		}
+		// the next match follows a rune that isn't part of a word, which
+		// can't start before end
+		_, size := utf8.DecodeLastRune(line[:end])
+		from := end - size
+		if loc = finder.next.FindSubmatchIndex(line[from:]); loc != nil {
+			for i := range loc {
+				if loc[i] >= 0 {
+					loc[i] += from
+				}
+			}
+		}
+	}
+	return all
+}